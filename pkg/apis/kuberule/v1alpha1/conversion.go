@@ -0,0 +1,360 @@
+package v1alpha1
+
+import (
+	"github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// v1beta1 is the storage version, v1alpha1 objects are converted from and to it.
+// Both versions share the same status.
+
+var modesToV1beta1 = map[PodRuleMode]v1beta1.PodRuleMode{
+	PodRuleModeEnforce:  v1beta1.PodRuleModeEnforce,
+	PodRuleModeAudit:    v1beta1.PodRuleModeAudit,
+	PodRuleModeDisabled: v1beta1.PodRuleModeDisabled,
+}
+
+// ConvertTo converts this PodRule to the v1beta1 version
+func (src *PodRule) ConvertTo(dst *v1beta1.PodRule) {
+	dst.ObjectMeta = src.ObjectMeta
+	convertPodRuleSpecToV1beta1(&src.Spec, &dst.Spec)
+	convertPodRuleStatusToV1beta1(&src.Status, &dst.Status)
+}
+
+// ConvertFrom converts the v1beta1 version of a PodRule to this version
+func (dst *PodRule) ConvertFrom(src *v1beta1.PodRule) {
+	dst.ObjectMeta = src.ObjectMeta
+	convertPodRuleSpecFromV1beta1(&src.Spec, &dst.Spec)
+	convertPodRuleStatusFromV1beta1(&src.Status, &dst.Status)
+}
+
+// ConvertTo converts this PodRulePolicy to the v1beta1 version
+func (src *PodRulePolicy) ConvertTo(dst *v1beta1.PodRulePolicy) {
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1beta1.PodRulePolicySpec{
+		AllowedMutations:          src.Spec.AllowedMutations,
+		AllowedAnnotationPrefixes: src.Spec.AllowedAnnotationPrefixes,
+		AllowedLabelPrefixes:      src.Spec.AllowedLabelPrefixes,
+		AllowedTolerationKeys:     src.Spec.AllowedTolerationKeys,
+		AllowedNodeSelectorKeys:   src.Spec.AllowedNodeSelectorKeys,
+	}
+	// empty selector selects all namespaces, same as no selector in v1beta1
+	if selector := src.Spec.NamespaceSelector; len(selector.MatchLabels) > 0 || len(selector.MatchExpressions) > 0 {
+		dst.Spec.NamespaceSelector = &selector
+	}
+}
+
+// ConvertFrom converts the v1beta1 version of a PodRulePolicy to this version
+func (dst *PodRulePolicy) ConvertFrom(src *v1beta1.PodRulePolicy) {
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = PodRulePolicySpec{
+		AllowedMutations:          src.Spec.AllowedMutations,
+		AllowedAnnotationPrefixes: src.Spec.AllowedAnnotationPrefixes,
+		AllowedLabelPrefixes:      src.Spec.AllowedLabelPrefixes,
+		AllowedTolerationKeys:     src.Spec.AllowedTolerationKeys,
+		AllowedNodeSelectorKeys:   src.Spec.AllowedNodeSelectorKeys,
+	}
+	if src.Spec.NamespaceSelector != nil {
+		dst.Spec.NamespaceSelector = *src.Spec.NamespaceSelector
+	} else {
+		dst.Spec.NamespaceSelector = metav1.LabelSelector{}
+	}
+}
+
+func convertPodRuleSpecToV1beta1(in *PodRuleSpec, out *v1beta1.PodRuleSpec) {
+	out.ApplyOrder = in.ApplyOrder
+	out.Selector = in.Selector
+	out.MatchAll = in.MatchAll
+	out.NamespaceSelector = in.NamespaceSelector
+	out.Profiles = nil
+	if in.Profiles != nil {
+		out.Profiles = make([]v1beta1.PodMutationProfileReference, len(in.Profiles))
+		for i, profile := range in.Profiles {
+			out.Profiles[i] = v1beta1.PodMutationProfileReference{
+				Kind: v1beta1.PodMutationProfileKind(profile.Kind),
+				Name: profile.Name,
+			}
+		}
+	}
+	out.Mutations = v1beta1.PodMutations{
+		Annotations:                   in.Mutations.Annotations,
+		Labels:                        in.Mutations.Labels,
+		Affinity:                      in.Mutations.Affinity,
+		NodeSelector:                  in.Mutations.NodeSelector,
+		ImagePullSecrets:              in.Mutations.ImagePullSecrets,
+		Tolerations:                   in.Mutations.Tolerations,
+		ServiceAccountName:            in.Mutations.ServiceAccountName,
+		AutomountServiceAccountToken:  in.Mutations.AutomountServiceAccountToken,
+		DNSPolicy:                     in.Mutations.DNSPolicy,
+		DNSConfig:                     in.Mutations.DNSConfig,
+		HostAliases:                   in.Mutations.HostAliases,
+		TerminationGracePeriodSeconds: in.Mutations.TerminationGracePeriodSeconds,
+		PreStop:                       convertContainerPreStopToV1beta1(in.Mutations.PreStop),
+		ReadinessGates:                in.Mutations.ReadinessGates,
+		ImageRegistryRewrites:         convertImageRegistryRewritesToV1beta1(in.Mutations.ImageRegistryRewrites),
+		ImagePullPolicy:               in.Mutations.ImagePullPolicy,
+		Volumes:                       in.Mutations.Volumes,
+		VolumeMounts:                  in.Mutations.VolumeMounts,
+		Containers:                    convertContainerSelectorToV1beta1(in.Mutations.Containers),
+		Strategies: v1beta1.PodMutationStrategies{
+			Annotations:                   v1beta1.MutationStrategy(in.Strategies.Annotations),
+			Labels:                        v1beta1.MutationStrategy(in.Strategies.Labels),
+			Affinity:                      v1beta1.MutationStrategy(in.Strategies.Affinity),
+			NodeSelector:                  v1beta1.MutationStrategy(in.Strategies.NodeSelector),
+			ImagePullSecrets:              v1beta1.MutationStrategy(in.Strategies.ImagePullSecrets),
+			Tolerations:                   v1beta1.MutationStrategy(in.Strategies.Tolerations),
+			ServiceAccountName:            v1beta1.MutationStrategy(in.Strategies.ServiceAccountName),
+			AutomountServiceAccountToken:  v1beta1.MutationStrategy(in.Strategies.AutomountServiceAccountToken),
+			DNSPolicy:                     v1beta1.MutationStrategy(in.Strategies.DNSPolicy),
+			DNSConfig:                     v1beta1.MutationStrategy(in.Strategies.DNSConfig),
+			HostAliases:                   v1beta1.MutationStrategy(in.Strategies.HostAliases),
+			TerminationGracePeriodSeconds: v1beta1.MutationStrategy(in.Strategies.TerminationGracePeriodSeconds),
+			ReadinessGates:                v1beta1.MutationStrategy(in.Strategies.ReadinessGates),
+			ImagePullPolicy:               v1beta1.MutationStrategy(in.Strategies.ImagePullPolicy),
+		},
+	}
+	out.ImagePullSecretSources = nil
+	if in.ImagePullSecretSources != nil {
+		out.ImagePullSecretSources = make([]v1beta1.SecretSourceReference, len(in.ImagePullSecretSources))
+		for i, source := range in.ImagePullSecretSources {
+			out.ImagePullSecretSources[i] = v1beta1.SecretSourceReference{Namespace: source.Namespace, Name: source.Name}
+		}
+	}
+	out.Validations = nil
+	if in.Validations != nil {
+		out.Validations = &v1beta1.PodValidations{
+			RequiredLabels:      in.Validations.RequiredLabels,
+			RequiredAnnotations: in.Validations.RequiredAnnotations,
+			MustNotTolerate:     in.Validations.MustNotTolerate,
+		}
+	}
+	out.Mode = v1beta1.PodRuleMode(in.Mode)
+	if mode, ok := modesToV1beta1[in.Mode]; ok {
+		out.Mode = mode
+	}
+	out.Rollout = nil
+	if in.Rollout != nil {
+		out.Rollout = &v1beta1.PodRuleRollout{Percentage: in.Rollout.Percentage}
+	}
+	out.ActiveFrom = in.ActiveFrom
+	out.ActiveUntil = in.ActiveUntil
+	out.Schedule = nil
+	if in.Schedule != nil {
+		out.Schedule = &v1beta1.PodRuleSchedule{Cron: in.Schedule.Cron, Duration: in.Schedule.Duration}
+	}
+	out.Restart = nil
+	if in.Restart != nil {
+		out.Restart = &v1beta1.PodRuleRestart{MinInterval: in.Restart.MinInterval}
+	}
+	out.OnDeletion = nil
+	if in.OnDeletion != nil {
+		out.OnDeletion = &v1beta1.PodRuleDeletion{StripMetadata: in.OnDeletion.StripMetadata}
+	}
+}
+
+func convertPodRuleSpecFromV1beta1(in *v1beta1.PodRuleSpec, out *PodRuleSpec) {
+	out.ApplyOrder = in.ApplyOrder
+	out.Selector = in.Selector
+	out.MatchAll = in.MatchAll
+	out.NamespaceSelector = in.NamespaceSelector
+	out.Profiles = nil
+	if in.Profiles != nil {
+		out.Profiles = make([]PodMutationProfileReference, len(in.Profiles))
+		for i, profile := range in.Profiles {
+			out.Profiles[i] = PodMutationProfileReference{
+				Kind: PodMutationProfileKind(profile.Kind),
+				Name: profile.Name,
+			}
+		}
+	}
+	out.Mutations = PodMutations{
+		Annotations:                   in.Mutations.Annotations,
+		Labels:                        in.Mutations.Labels,
+		Affinity:                      in.Mutations.Affinity,
+		NodeSelector:                  in.Mutations.NodeSelector,
+		ImagePullSecrets:              in.Mutations.ImagePullSecrets,
+		Tolerations:                   in.Mutations.Tolerations,
+		ServiceAccountName:            in.Mutations.ServiceAccountName,
+		AutomountServiceAccountToken:  in.Mutations.AutomountServiceAccountToken,
+		DNSPolicy:                     in.Mutations.DNSPolicy,
+		DNSConfig:                     in.Mutations.DNSConfig,
+		HostAliases:                   in.Mutations.HostAliases,
+		TerminationGracePeriodSeconds: in.Mutations.TerminationGracePeriodSeconds,
+		PreStop:                       convertContainerPreStopFromV1beta1(in.Mutations.PreStop),
+		ReadinessGates:                in.Mutations.ReadinessGates,
+		ImageRegistryRewrites:         convertImageRegistryRewritesFromV1beta1(in.Mutations.ImageRegistryRewrites),
+		ImagePullPolicy:               in.Mutations.ImagePullPolicy,
+		Volumes:                       in.Mutations.Volumes,
+		VolumeMounts:                  in.Mutations.VolumeMounts,
+		Containers:                    convertContainerSelectorFromV1beta1(in.Mutations.Containers),
+	}
+	out.Strategies = PodMutationStrategies{
+		Annotations:                   MutationStrategy(in.Mutations.Strategies.Annotations),
+		Labels:                        MutationStrategy(in.Mutations.Strategies.Labels),
+		Affinity:                      MutationStrategy(in.Mutations.Strategies.Affinity),
+		NodeSelector:                  MutationStrategy(in.Mutations.Strategies.NodeSelector),
+		ImagePullSecrets:              MutationStrategy(in.Mutations.Strategies.ImagePullSecrets),
+		Tolerations:                   MutationStrategy(in.Mutations.Strategies.Tolerations),
+		ServiceAccountName:            MutationStrategy(in.Mutations.Strategies.ServiceAccountName),
+		AutomountServiceAccountToken:  MutationStrategy(in.Mutations.Strategies.AutomountServiceAccountToken),
+		DNSPolicy:                     MutationStrategy(in.Mutations.Strategies.DNSPolicy),
+		DNSConfig:                     MutationStrategy(in.Mutations.Strategies.DNSConfig),
+		HostAliases:                   MutationStrategy(in.Mutations.Strategies.HostAliases),
+		TerminationGracePeriodSeconds: MutationStrategy(in.Mutations.Strategies.TerminationGracePeriodSeconds),
+		ReadinessGates:                MutationStrategy(in.Mutations.Strategies.ReadinessGates),
+		ImagePullPolicy:               MutationStrategy(in.Mutations.Strategies.ImagePullPolicy),
+	}
+	out.ImagePullSecretSources = nil
+	if in.ImagePullSecretSources != nil {
+		out.ImagePullSecretSources = make([]SecretSourceReference, len(in.ImagePullSecretSources))
+		for i, source := range in.ImagePullSecretSources {
+			out.ImagePullSecretSources[i] = SecretSourceReference{Namespace: source.Namespace, Name: source.Name}
+		}
+	}
+	out.Validations = nil
+	if in.Validations != nil {
+		out.Validations = &PodValidations{
+			RequiredLabels:      in.Validations.RequiredLabels,
+			RequiredAnnotations: in.Validations.RequiredAnnotations,
+			MustNotTolerate:     in.Validations.MustNotTolerate,
+		}
+	}
+	out.Mode = PodRuleMode(in.Mode)
+	for mode, v1beta1Mode := range modesToV1beta1 {
+		if in.Mode == v1beta1Mode {
+			out.Mode = mode
+		}
+	}
+	out.Rollout = nil
+	if in.Rollout != nil {
+		out.Rollout = &PodRuleRollout{Percentage: in.Rollout.Percentage}
+	}
+	out.ActiveFrom = in.ActiveFrom
+	out.ActiveUntil = in.ActiveUntil
+	out.Schedule = nil
+	if in.Schedule != nil {
+		out.Schedule = &PodRuleSchedule{Cron: in.Schedule.Cron, Duration: in.Schedule.Duration}
+	}
+	out.Restart = nil
+	if in.Restart != nil {
+		out.Restart = &PodRuleRestart{MinInterval: in.Restart.MinInterval}
+	}
+	out.OnDeletion = nil
+	if in.OnDeletion != nil {
+		out.OnDeletion = &PodRuleDeletion{StripMetadata: in.OnDeletion.StripMetadata}
+	}
+}
+
+func convertContainerSelectorToV1beta1(in *ContainerSelector) *v1beta1.ContainerSelector {
+	if in == nil {
+		return nil
+	}
+	out := &v1beta1.ContainerSelector{Names: in.Names, ExcludeNames: in.ExcludeNames, Images: in.Images}
+	for _, containerType := range in.Types {
+		out.Types = append(out.Types, v1beta1.ContainerType(containerType))
+	}
+	return out
+}
+
+func convertContainerSelectorFromV1beta1(in *v1beta1.ContainerSelector) *ContainerSelector {
+	if in == nil {
+		return nil
+	}
+	out := &ContainerSelector{Names: in.Names, ExcludeNames: in.ExcludeNames, Images: in.Images}
+	for _, containerType := range in.Types {
+		out.Types = append(out.Types, ContainerType(containerType))
+	}
+	return out
+}
+
+func convertContainerPreStopToV1beta1(in *ContainerPreStop) *v1beta1.ContainerPreStop {
+	if in == nil {
+		return nil
+	}
+	return &v1beta1.ContainerPreStop{Containers: in.Containers, Handler: in.Handler}
+}
+
+func convertContainerPreStopFromV1beta1(in *v1beta1.ContainerPreStop) *ContainerPreStop {
+	if in == nil {
+		return nil
+	}
+	return &ContainerPreStop{Containers: in.Containers, Handler: in.Handler}
+}
+
+func convertImageRegistryRewritesToV1beta1(in []ImageRegistryRewrite) []v1beta1.ImageRegistryRewrite {
+	if in == nil {
+		return nil
+	}
+	out := make([]v1beta1.ImageRegistryRewrite, len(in))
+	for i := range in {
+		out[i] = v1beta1.ImageRegistryRewrite{From: in[i].From, To: in[i].To}
+	}
+	return out
+}
+
+func convertImageRegistryRewritesFromV1beta1(in []v1beta1.ImageRegistryRewrite) []ImageRegistryRewrite {
+	if in == nil {
+		return nil
+	}
+	out := make([]ImageRegistryRewrite, len(in))
+	for i := range in {
+		out[i] = ImageRegistryRewrite{From: in[i].From, To: in[i].To}
+	}
+	return out
+}
+
+func convertPodRuleStatusToV1beta1(in *PodRuleStatus, out *v1beta1.PodRuleStatus) {
+	out.Conditions = nil
+	if in.Conditions != nil {
+		out.Conditions = make([]v1beta1.PodRuleCondition, len(in.Conditions))
+		for i, condition := range in.Conditions {
+			out.Conditions[i] = v1beta1.PodRuleCondition{
+				Type:               v1beta1.PodRuleConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: condition.LastTransitionTime,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	out.MatchedPods = in.MatchedPods
+	out.AuditedPods = in.AuditedPods
+	out.LastAudits = nil
+	if in.LastAudits != nil {
+		out.LastAudits = make([]v1beta1.PodRuleAudit, len(in.LastAudits))
+		for i, audit := range in.LastAudits {
+			out.LastAudits[i] = v1beta1.PodRuleAudit(audit)
+		}
+	}
+	out.DriftedPods = in.DriftedPods
+	out.DriftedWorkloads = in.DriftedWorkloads
+	out.LastRestartTime = in.LastRestartTime
+}
+
+func convertPodRuleStatusFromV1beta1(in *v1beta1.PodRuleStatus, out *PodRuleStatus) {
+	out.Conditions = nil
+	if in.Conditions != nil {
+		out.Conditions = make([]PodRuleCondition, len(in.Conditions))
+		for i, condition := range in.Conditions {
+			out.Conditions[i] = PodRuleCondition{
+				Type:               PodRuleConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: condition.LastTransitionTime,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	out.MatchedPods = in.MatchedPods
+	out.AuditedPods = in.AuditedPods
+	out.LastAudits = nil
+	if in.LastAudits != nil {
+		out.LastAudits = make([]PodRuleAudit, len(in.LastAudits))
+		for i, audit := range in.LastAudits {
+			out.LastAudits[i] = PodRuleAudit(audit)
+		}
+	}
+	out.DriftedPods = in.DriftedPods
+	out.DriftedWorkloads = in.DriftedWorkloads
+	out.LastRestartTime = in.LastRestartTime
+}
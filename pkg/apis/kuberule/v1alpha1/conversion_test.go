@@ -0,0 +1,89 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	fuzz "github.com/google/gofuzz"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newConversionFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().NilChance(.5).Funcs(
+		// modes are validated by the CRD schema
+		func(mode *PodRuleMode, c fuzz.Continue) {
+			modes := []PodRuleMode{"", PodRuleModeEnforce, PodRuleModeAudit, PodRuleModeDisabled}
+			*mode = modes[c.Intn(len(modes))]
+		},
+		func(mode *v1beta1.PodRuleMode, c fuzz.Continue) {
+			modes := []v1beta1.PodRuleMode{"", v1beta1.PodRuleModeEnforce, v1beta1.PodRuleModeAudit, v1beta1.PodRuleModeDisabled}
+			*mode = modes[c.Intn(len(modes))]
+		},
+		// an empty namespaceSelector selects all namespaces, same as no selector
+		func(spec *v1beta1.PodRulePolicySpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			if selector := spec.NamespaceSelector; selector != nil && len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+				spec.NamespaceSelector = nil
+			}
+		},
+	)
+}
+
+func TestPodRuleConversionRoundTrip(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	f := newConversionFuzzer()
+
+	for i := 0; i < 1000; i++ {
+		original := &PodRule{}
+		f.Fuzz(original)
+		original.TypeMeta = metav1.TypeMeta{}
+
+		hub := &v1beta1.PodRule{}
+		original.ConvertTo(hub)
+		converted := &PodRule{}
+		converted.ConvertFrom(hub)
+		g.Expect(converted).To(gomega.Equal(original))
+	}
+
+	for i := 0; i < 1000; i++ {
+		original := &v1beta1.PodRule{}
+		f.Fuzz(original)
+		original.TypeMeta = metav1.TypeMeta{}
+
+		spoke := &PodRule{}
+		spoke.ConvertFrom(original)
+		converted := &v1beta1.PodRule{}
+		spoke.ConvertTo(converted)
+		g.Expect(converted).To(gomega.Equal(original))
+	}
+}
+
+func TestPodRulePolicyConversionRoundTrip(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	f := newConversionFuzzer()
+
+	for i := 0; i < 1000; i++ {
+		original := &PodRulePolicy{}
+		f.Fuzz(original)
+		original.TypeMeta = metav1.TypeMeta{}
+
+		hub := &v1beta1.PodRulePolicy{}
+		original.ConvertTo(hub)
+		converted := &PodRulePolicy{}
+		converted.ConvertFrom(hub)
+		g.Expect(converted).To(gomega.Equal(original))
+	}
+
+	for i := 0; i < 1000; i++ {
+		original := &v1beta1.PodRulePolicy{}
+		f.Fuzz(original)
+		original.TypeMeta = metav1.TypeMeta{}
+
+		spoke := &PodRulePolicy{}
+		spoke.ConvertFrom(original)
+		converted := &v1beta1.PodRulePolicy{}
+		spoke.ConvertTo(converted)
+		g.Expect(converted).To(gomega.Equal(original))
+	}
+}
@@ -6,9 +6,116 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerPreStop) DeepCopyInto(out *ContainerPreStop) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Handler.DeepCopyInto(&out.Handler)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerPreStop.
+func (in *ContainerPreStop) DeepCopy() *ContainerPreStop {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerPreStop)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerSelector) DeepCopyInto(out *ContainerSelector) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeNames != nil {
+		in, out := &in.ExcludeNames, &out.ExcludeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Types != nil {
+		in, out := &in.Types, &out.Types
+		*out = make([]ContainerType, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerSelector.
+func (in *ContainerSelector) DeepCopy() *ContainerSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryRewrite) DeepCopyInto(out *ImageRegistryRewrite) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryRewrite.
+func (in *ImageRegistryRewrite) DeepCopy() *ImageRegistryRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMutationProfileReference) DeepCopyInto(out *PodMutationProfileReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMutationProfileReference.
+func (in *PodMutationProfileReference) DeepCopy() *PodMutationProfileReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMutationProfileReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMutationStrategies) DeepCopyInto(out *PodMutationStrategies) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMutationStrategies.
+func (in *PodMutationStrategies) DeepCopy() *PodMutationStrategies {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMutationStrategies)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodMutations) DeepCopyInto(out *PodMutations) {
 	*out = *in
@@ -19,6 +126,13 @@ func (in *PodMutations) DeepCopyInto(out *PodMutations) {
 			(*out)[key] = val
 		}
 	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Affinity != nil {
 		in, out := &in.Affinity, &out.Affinity
 		*out = new(v1.Affinity)
@@ -43,6 +157,62 @@ func (in *PodMutations) DeepCopyInto(out *PodMutations) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PreStop != nil {
+		in, out := &in.PreStop, &out.PreStop
+		*out = new(ContainerPreStop)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]v1.PodReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageRegistryRewrites != nil {
+		in, out := &in.ImageRegistryRewrites, &out.ImageRegistryRewrites
+		*out = make([]ImageRegistryRewrite, len(*in))
+		copy(*out, *in)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = new(ContainerSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -62,7 +232,7 @@ func (in *PodRule) DeepCopyInto(out *PodRule) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -84,6 +254,56 @@ func (in *PodRule) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleAudit) DeepCopyInto(out *PodRuleAudit) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleAudit.
+func (in *PodRuleAudit) DeepCopy() *PodRuleAudit {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleAudit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleCondition) DeepCopyInto(out *PodRuleCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleCondition.
+func (in *PodRuleCondition) DeepCopy() *PodRuleCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleDeletion) DeepCopyInto(out *PodRuleDeletion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleDeletion.
+func (in *PodRuleDeletion) DeepCopy() *PodRuleDeletion {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleDeletion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodRuleList) DeepCopyInto(out *PodRuleList) {
 	*out = *in
@@ -117,11 +337,216 @@ func (in *PodRuleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRulePolicy) DeepCopyInto(out *PodRulePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRulePolicy.
+func (in *PodRulePolicy) DeepCopy() *PodRulePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRulePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PodRulePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRulePolicyList) DeepCopyInto(out *PodRulePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PodRulePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRulePolicyList.
+func (in *PodRulePolicyList) DeepCopy() *PodRulePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRulePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PodRulePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRulePolicySpec) DeepCopyInto(out *PodRulePolicySpec) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.AllowedMutations != nil {
+		in, out := &in.AllowedMutations, &out.AllowedMutations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedAnnotationPrefixes != nil {
+		in, out := &in.AllowedAnnotationPrefixes, &out.AllowedAnnotationPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedLabelPrefixes != nil {
+		in, out := &in.AllowedLabelPrefixes, &out.AllowedLabelPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedTolerationKeys != nil {
+		in, out := &in.AllowedTolerationKeys, &out.AllowedTolerationKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedNodeSelectorKeys != nil {
+		in, out := &in.AllowedNodeSelectorKeys, &out.AllowedNodeSelectorKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRulePolicySpec.
+func (in *PodRulePolicySpec) DeepCopy() *PodRulePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRulePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleRestart) DeepCopyInto(out *PodRuleRestart) {
+	*out = *in
+	if in.MinInterval != nil {
+		in, out := &in.MinInterval, &out.MinInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleRestart.
+func (in *PodRuleRestart) DeepCopy() *PodRuleRestart {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleRestart)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleRollout) DeepCopyInto(out *PodRuleRollout) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleRollout.
+func (in *PodRuleRollout) DeepCopy() *PodRuleRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRuleSchedule) DeepCopyInto(out *PodRuleSchedule) {
+	*out = *in
+	out.Duration = in.Duration
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRuleSchedule.
+func (in *PodRuleSchedule) DeepCopy() *PodRuleSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRuleSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodRuleSpec) DeepCopyInto(out *PodRuleSpec) {
 	*out = *in
 	in.Selector.DeepCopyInto(&out.Selector)
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]PodMutationProfileReference, len(*in))
+		copy(*out, *in)
+	}
 	in.Mutations.DeepCopyInto(&out.Mutations)
+	out.Strategies = in.Strategies
+	if in.ImagePullSecretSources != nil {
+		in, out := &in.ImagePullSecretSources, &out.ImagePullSecretSources
+		*out = make([]SecretSourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Validations != nil {
+		in, out := &in.Validations, &out.Validations
+		*out = new(PodValidations)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(PodRuleRollout)
+		**out = **in
+	}
+	if in.ActiveFrom != nil {
+		in, out := &in.ActiveFrom, &out.ActiveFrom
+		*out = (*in).DeepCopy()
+	}
+	if in.ActiveUntil != nil {
+		in, out := &in.ActiveUntil, &out.ActiveUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(PodRuleSchedule)
+		**out = **in
+	}
+	if in.Restart != nil {
+		in, out := &in.Restart, &out.Restart
+		*out = new(PodRuleRestart)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OnDeletion != nil {
+		in, out := &in.OnDeletion, &out.OnDeletion
+		*out = new(PodRuleDeletion)
+		**out = **in
+	}
 	return
 }
 
@@ -138,6 +563,29 @@ func (in *PodRuleSpec) DeepCopy() *PodRuleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodRuleStatus) DeepCopyInto(out *PodRuleStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]PodRuleCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastAudits != nil {
+		in, out := &in.LastAudits, &out.LastAudits
+		*out = make([]PodRuleAudit, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DriftedWorkloads != nil {
+		in, out := &in.DriftedWorkloads, &out.DriftedWorkloads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastRestartTime != nil {
+		in, out := &in.LastRestartTime, &out.LastRestartTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -150,3 +598,52 @@ func (in *PodRuleStatus) DeepCopy() *PodRuleStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodValidations) DeepCopyInto(out *PodValidations) {
+	*out = *in
+	if in.RequiredLabels != nil {
+		in, out := &in.RequiredLabels, &out.RequiredLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredAnnotations != nil {
+		in, out := &in.RequiredAnnotations, &out.RequiredAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MustNotTolerate != nil {
+		in, out := &in.MustNotTolerate, &out.MustNotTolerate
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodValidations.
+func (in *PodValidations) DeepCopy() *PodValidations {
+	if in == nil {
+		return nil
+	}
+	out := new(PodValidations)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretSourceReference) DeepCopyInto(out *SecretSourceReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretSourceReference.
+func (in *SecretSourceReference) DeepCopy() *SecretSourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretSourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
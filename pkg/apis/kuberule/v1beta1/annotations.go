@@ -0,0 +1,42 @@
+package v1beta1
+
+// Annotations recognized or set on pods, namespaces and secrets
+const (
+	// AnnotationSkip set to "true" prevents any rule from mutating the pods
+	AnnotationSkip = "kuberule.chickenzord.com/skip"
+
+	// AnnotationExcludeRules is a comma-separated list of rule names not to be applied on the pods
+	AnnotationExcludeRules = "kuberule.chickenzord.com/exclude-rules"
+
+	// AnnotationEnabled set to "true" allows rules to mutate the pods when running in opt-in mode
+	AnnotationEnabled = "kuberule.chickenzord.com/enabled"
+
+	// AnnotationAuditedRules is set by kuberule to the comma-separated list of audit mode rules matching the pods
+	AnnotationAuditedRules = "kuberule.chickenzord.com/audited-rules"
+
+	// AnnotationReplicable set to "true" allows the secret to be copied into other namespaces by PodRules
+	AnnotationReplicable = "kuberule.chickenzord.com/replicable"
+
+	// AnnotationReplicatedFrom is set by kuberule on secret copies, to the namespace/name of their source
+	AnnotationReplicatedFrom = "kuberule.chickenzord.com/replicated-from"
+
+	// AnnotationAppliedRules is set by kuberule on created pods to the comma-separated list of rule=hash
+	// of the enforced rules, hashes of their mutations being used to detect rule changes
+	AnnotationAppliedRules = "kuberule.chickenzord.com/applied-rules"
+
+	// AnnotationRestartedAt is set by kuberule on the pod template of workloads it restarts
+	AnnotationRestartedAt = "kuberule.chickenzord.com/restarted-at"
+
+	// AnnotationNodeSelector is a comma-separated list of key=value node labels to be added to the pods of the namespace
+	AnnotationNodeSelector = "kuberule.chickenzord.com/node-selector"
+
+	// AnnotationTolerations is a JSON list of tolerations to be added to the pods of the namespace
+	AnnotationTolerations = "kuberule.chickenzord.com/tolerations"
+
+	// AnnotationMutations is a JSON PodMutations to be applied on the pods of the namespace
+	AnnotationMutations = "kuberule.chickenzord.com/mutations"
+)
+
+// NamespaceRuleName is the name of the implicit rule made of the namespace annotations.
+// It can't collide with PodRule names, slashes being invalid in object names.
+const NamespaceRuleName = "kuberule.chickenzord.com/namespace"
@@ -0,0 +1,7 @@
+// Package v1beta1 contains API Schema definitions for the kuberule v1beta1 API group
+// +k8s:openapi-gen=true
+// +k8s:deepcopy-gen=package,register
+// +k8s:conversion-gen=github.com/chickenzord/kube-rule/pkg/apis/kuberule
+// +k8s:defaulter-gen=TypeMeta
+// +groupName=kuberule.chickenzord.com
+package v1beta1
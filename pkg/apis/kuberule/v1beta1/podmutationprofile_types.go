@@ -0,0 +1,68 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodMutationProfileSpec defines mutations shared by the PodRules referencing the profile
+type PodMutationProfileSpec struct {
+	// Mutations to be done on the pods selected by the referencing rules
+	Mutations PodMutations `json:"mutations"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodMutationProfile is the Schema for the podmutationprofiles API.
+// It can be referenced by the PodRules of its namespace.
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=podmutationprofiles,shortName=pmp
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type PodMutationProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodMutationProfileSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodMutationProfileList contains a list of PodMutationProfile
+type PodMutationProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodMutationProfile `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterPodMutationProfile is the Schema for the clusterpodmutationprofiles API.
+// It can be referenced by PodRules of any namespace.
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=clusterpodmutationprofiles,scope=Cluster,shortName=cpmp
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type ClusterPodMutationProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodMutationProfileSpec `json:"spec,omitempty"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterPodMutationProfileList contains a list of ClusterPodMutationProfile
+type ClusterPodMutationProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPodMutationProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(
+		&PodMutationProfile{}, &PodMutationProfileList{},
+		&ClusterPodMutationProfile{}, &ClusterPodMutationProfileList{},
+	)
+}
@@ -0,0 +1,502 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Finalizer set on PodRules, released by the controller on deletion
+const Finalizer = "kuberule.chickenzord.com/finalizer"
+
+// DefaultRestartMinInterval is the minimum interval between two workload restarts triggered by a rule
+const DefaultRestartMinInterval = 10 * time.Minute
+
+// Reasons of the PodRuleActive condition
+const (
+	ReasonActive          = "Active"
+	ReasonNotYetActive    = "NotYetActive"
+	ReasonExpired         = "Expired"
+	ReasonOutsideSchedule = "OutsideSchedule"
+	ReasonInvalidSchedule = "InvalidSchedule"
+)
+
+// Reasons of the PodRuleProfilesResolved condition
+const (
+	ReasonProfilesResolved = "ProfilesResolved"
+	ReasonProfileNotFound  = "ProfileNotFound"
+)
+
+// Reasons of the PodRuleImagePullSecretsReplicated condition
+const (
+	ReasonSecretsReplicated   = "SecretsReplicated"
+	ReasonSecretNotFound      = "SecretNotFound"
+	ReasonSecretNotReplicable = "SecretNotReplicable"
+	ReasonSecretConflict      = "SecretConflict"
+)
+
+// Default sets the default strategy of each mutation not having one
+func (s *PodMutationStrategies) Default() {
+	defaultStrategy := func(strategy *MutationStrategy, value MutationStrategy) {
+		if *strategy == "" {
+			*strategy = value
+		}
+	}
+
+	defaultStrategy(&s.Annotations, MutationStrategyMerge)
+	defaultStrategy(&s.Labels, MutationStrategyMerge)
+	defaultStrategy(&s.Affinity, MutationStrategyKeepExisting)
+	defaultStrategy(&s.NodeSelector, MutationStrategyKeepExisting)
+	defaultStrategy(&s.ImagePullSecrets, MutationStrategyMerge)
+	defaultStrategy(&s.Tolerations, MutationStrategyMerge)
+	defaultStrategy(&s.ServiceAccountName, MutationStrategyKeepExisting)
+	defaultStrategy(&s.AutomountServiceAccountToken, MutationStrategyKeepExisting)
+	defaultStrategy(&s.DNSPolicy, MutationStrategyKeepExisting)
+	defaultStrategy(&s.DNSConfig, MutationStrategyMerge)
+	defaultStrategy(&s.HostAliases, MutationStrategyMerge)
+	defaultStrategy(&s.TerminationGracePeriodSeconds, MutationStrategyMerge)
+	defaultStrategy(&s.ReadinessGates, MutationStrategyMerge)
+	defaultStrategy(&s.ImagePullPolicy, MutationStrategyKeepExisting)
+}
+
+// Merge merges the other mutations into these ones, the other values winning:
+// maps are merged, set scalars, affinity, preStop and containers replace the existing ones, missing list items
+// are appended, and set strategies replace the existing ones
+func (m *PodMutations) Merge(other *PodMutations) {
+	mergeMap := func(dst *map[string]string, src map[string]string) {
+		if len(src) == 0 {
+			return
+		}
+		if *dst == nil {
+			*dst = make(map[string]string, len(src))
+		}
+		for key, val := range src {
+			(*dst)[key] = val
+		}
+	}
+
+	mergeMap(&m.Annotations, other.Annotations)
+	mergeMap(&m.Labels, other.Labels)
+	mergeMap(&m.NodeSelector, other.NodeSelector)
+
+	if other.Affinity != nil {
+		m.Affinity = other.Affinity.DeepCopy()
+	}
+
+	for _, secret := range other.ImagePullSecrets {
+		found := false
+		for _, existing := range m.ImagePullSecrets {
+			if existing.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.ImagePullSecrets = append(m.ImagePullSecrets, secret)
+		}
+	}
+
+	for _, toleration := range other.Tolerations {
+		found := false
+		for i := range m.Tolerations {
+			if m.Tolerations[i].MatchToleration(&toleration) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.Tolerations = append(m.Tolerations, *toleration.DeepCopy())
+		}
+	}
+
+	if other.ServiceAccountName != "" {
+		m.ServiceAccountName = other.ServiceAccountName
+	}
+	if other.AutomountServiceAccountToken != nil {
+		automount := *other.AutomountServiceAccountToken
+		m.AutomountServiceAccountToken = &automount
+	}
+
+	if other.DNSPolicy != "" {
+		m.DNSPolicy = other.DNSPolicy
+	}
+	m.DNSConfig = MergePodDNSConfig(m.DNSConfig, other.DNSConfig)
+	m.HostAliases = MergeHostAliases(m.HostAliases, other.HostAliases)
+
+	if other.TerminationGracePeriodSeconds != nil {
+		period := *other.TerminationGracePeriodSeconds
+		m.TerminationGracePeriodSeconds = &period
+	}
+	if other.PreStop != nil {
+		m.PreStop = other.PreStop.DeepCopy()
+	}
+	m.ReadinessGates = MergeReadinessGates(m.ReadinessGates, other.ReadinessGates)
+
+	for _, rewrite := range other.ImageRegistryRewrites {
+		found := false
+		for i := range m.ImageRegistryRewrites {
+			if m.ImageRegistryRewrites[i].From == rewrite.From {
+				m.ImageRegistryRewrites[i].To = rewrite.To
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.ImageRegistryRewrites = append(m.ImageRegistryRewrites, rewrite)
+		}
+	}
+	if other.ImagePullPolicy != "" {
+		m.ImagePullPolicy = other.ImagePullPolicy
+	}
+	m.Volumes = MergeVolumes(m.Volumes, other.Volumes)
+	m.VolumeMounts = MergeVolumeMounts(m.VolumeMounts, other.VolumeMounts)
+	if other.Containers != nil {
+		m.Containers = other.Containers.DeepCopy()
+	}
+
+	m.Strategies.Merge(&other.Strategies)
+}
+
+// MergePodDNSConfig returns the existing DNS config merged with the values:
+// missing nameservers and searches are appended, options are set by name
+func MergePodDNSConfig(existing, values *corev1.PodDNSConfig) *corev1.PodDNSConfig {
+	if values == nil {
+		return existing
+	}
+
+	merged := &corev1.PodDNSConfig{}
+	if existing != nil {
+		merged = existing.DeepCopy()
+	}
+	merged.Nameservers = appendMissing(merged.Nameservers, values.Nameservers)
+	merged.Searches = appendMissing(merged.Searches, values.Searches)
+	for _, option := range values.Options {
+		found := false
+		for i := range merged.Options {
+			if merged.Options[i].Name == option.Name {
+				merged.Options[i] = *option.DeepCopy()
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Options = append(merged.Options, *option.DeepCopy())
+		}
+	}
+	return merged
+}
+
+// MergeHostAliases returns the existing host aliases merged with the values:
+// hostnames of existing IPs are merged, aliases of other IPs are appended
+func MergeHostAliases(existing, values []corev1.HostAlias) []corev1.HostAlias {
+	if len(values) == 0 {
+		return existing
+	}
+
+	merged := make([]corev1.HostAlias, len(existing))
+	for i := range existing {
+		existing[i].DeepCopyInto(&merged[i])
+	}
+	for _, alias := range values {
+		found := false
+		for i := range merged {
+			if merged[i].IP == alias.IP {
+				merged[i].Hostnames = appendMissing(merged[i].Hostnames, alias.Hostnames)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, *alias.DeepCopy())
+		}
+	}
+	return merged
+}
+
+// MergeReadinessGates returns the existing readiness gates with the missing condition types appended
+func MergeReadinessGates(existing, values []corev1.PodReadinessGate) []corev1.PodReadinessGate {
+	if len(values) == 0 {
+		return existing
+	}
+
+	merged := append([]corev1.PodReadinessGate{}, existing...)
+	for _, gate := range values {
+		found := false
+		for _, existingGate := range merged {
+			if existingGate.ConditionType == gate.ConditionType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, gate)
+		}
+	}
+	return merged
+}
+
+// MergeVolumes returns the existing volumes with the volumes of other names appended
+func MergeVolumes(existing, values []corev1.Volume) []corev1.Volume {
+	if len(values) == 0 {
+		return existing
+	}
+
+	merged := append([]corev1.Volume{}, existing...)
+	for _, volume := range values {
+		found := false
+		for _, existingVolume := range merged {
+			if existingVolume.Name == volume.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, *volume.DeepCopy())
+		}
+	}
+	return merged
+}
+
+// MergeVolumeMounts returns the existing mounts with the mounts of other volumes and paths appended
+func MergeVolumeMounts(existing, values []corev1.VolumeMount) []corev1.VolumeMount {
+	if len(values) == 0 {
+		return existing
+	}
+
+	merged := append([]corev1.VolumeMount{}, existing...)
+	for _, mount := range values {
+		found := false
+		for _, existingMount := range merged {
+			if existingMount.Name == mount.Name || existingMount.MountPath == mount.MountPath {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, *mount.DeepCopy())
+		}
+	}
+	return merged
+}
+
+// appendMissing appends the values not already in the list
+func appendMissing(list, values []string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// Merge replaces strategies with the set ones of the other strategies
+func (s *PodMutationStrategies) Merge(other *PodMutationStrategies) {
+	mergeStrategy := func(strategy *MutationStrategy, value MutationStrategy) {
+		if value != "" {
+			*strategy = value
+		}
+	}
+
+	mergeStrategy(&s.Annotations, other.Annotations)
+	mergeStrategy(&s.Labels, other.Labels)
+	mergeStrategy(&s.Affinity, other.Affinity)
+	mergeStrategy(&s.NodeSelector, other.NodeSelector)
+	mergeStrategy(&s.ImagePullSecrets, other.ImagePullSecrets)
+	mergeStrategy(&s.Tolerations, other.Tolerations)
+	mergeStrategy(&s.ServiceAccountName, other.ServiceAccountName)
+	mergeStrategy(&s.AutomountServiceAccountToken, other.AutomountServiceAccountToken)
+	mergeStrategy(&s.DNSPolicy, other.DNSPolicy)
+	mergeStrategy(&s.DNSConfig, other.DNSConfig)
+	mergeStrategy(&s.HostAliases, other.HostAliases)
+	mergeStrategy(&s.TerminationGracePeriodSeconds, other.TerminationGracePeriodSeconds)
+	mergeStrategy(&s.ReadinessGates, other.ReadinessGates)
+	mergeStrategy(&s.ImagePullPolicy, other.ImagePullPolicy)
+}
+
+// ResolveMutations merges the mutations of the referenced profiles, in order, then the inline mutations.
+// getProfile returns the mutations of a referenced profile.
+func (s *PodRuleSpec) ResolveMutations(getProfile func(ref PodMutationProfileReference) (*PodMutations, error)) (*PodMutations, error) {
+	if len(s.Profiles) == 0 {
+		return s.Mutations.DeepCopy(), nil
+	}
+
+	mutations := &PodMutations{}
+	for _, ref := range s.Profiles {
+		profileMutations, err := getProfile(ref)
+		if err != nil {
+			return nil, err
+		}
+		mutations.Merge(profileMutations)
+	}
+	mutations.Merge(&s.Mutations)
+
+	return mutations, nil
+}
+
+// Hash returns a short hash of the mutations, recorded on pods to detect rule changes
+func (m *PodMutations) Hash() string {
+	// struct fields and map keys are marshalled in a stable order
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	hash := fnv.New32a()
+	hash.Write(raw)
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// ParseAppliedRules parses the value of the applied-rules annotation into mutation hashes by rule name
+func ParseAppliedRules(value string) map[string]string {
+	applied := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		if parts := strings.SplitN(strings.TrimSpace(item), "=", 2); len(parts) == 2 {
+			applied[parts[0]] = parts[1]
+		}
+	}
+	return applied
+}
+
+// FormatAppliedRules formats mutation hashes by rule name as the value of the applied-rules annotation
+func FormatAppliedRules(applied map[string]string) string {
+	items := []string{}
+	for name, hash := range applied {
+		items = append(items, name+"="+hash)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// MinIntervalOrDefault returns the minimum interval between two workload restarts
+func (r *PodRuleRestart) MinIntervalOrDefault() time.Duration {
+	if r.MinInterval == nil {
+		return DefaultRestartMinInterval
+	}
+	return r.MinInterval.Duration
+}
+
+// SelectsNamespace checks whether the namespace labels match the namespaceSelector, all namespaces matching without one
+func (s *PodRuleSpec) SelectsNamespace(namespace *corev1.Namespace) (bool, error) {
+	if s.NamespaceSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(s.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// ActiveAt checks whether the rule is active at the given time according to
+// activeFrom, activeUntil and schedule, returning the reason of the decision
+func (s *PodRuleSpec) ActiveAt(now time.Time) (bool, string, error) {
+	if s.ActiveFrom != nil && now.Before(s.ActiveFrom.Time) {
+		return false, ReasonNotYetActive, nil
+	}
+	if s.ActiveUntil != nil && !now.Before(s.ActiveUntil.Time) {
+		return false, ReasonExpired, nil
+	}
+	if s.Schedule == nil {
+		return true, ReasonActive, nil
+	}
+
+	schedule, err := cron.ParseStandard(s.Schedule.Cron)
+	if err != nil {
+		return false, ReasonInvalidSchedule, err
+	}
+
+	// active if a window started within the last duration
+	now = now.UTC()
+	if schedule.Next(now.Add(-s.Schedule.Duration.Duration)).After(now) {
+		return false, ReasonOutsideSchedule, nil
+	}
+	return true, ReasonActive, nil
+}
+
+// NextTransitionAfter returns the next time the rule may become active or inactive,
+// or nil if it will never change anymore
+func (s *PodRuleSpec) NextTransitionAfter(now time.Time) (*time.Time, error) {
+	var next *time.Time
+	consider := func(t time.Time) {
+		if t.After(now) && (next == nil || t.Before(*next)) {
+			next = &t
+		}
+	}
+
+	if s.ActiveFrom != nil {
+		consider(s.ActiveFrom.Time)
+	}
+	if s.ActiveUntil != nil {
+		consider(s.ActiveUntil.Time)
+	}
+	if s.Schedule != nil {
+		schedule, err := cron.ParseStandard(s.Schedule.Cron)
+		if err != nil {
+			return nil, err
+		}
+
+		now = now.UTC()
+		start := schedule.Next(now.Add(-s.Schedule.Duration.Duration))
+		if start.After(now) {
+			// outside of a window, next one starts at start
+			consider(start)
+		} else {
+			// inside a window
+			consider(start.Add(s.Schedule.Duration.Duration))
+		}
+	}
+
+	return next, nil
+}
+
+// GetCondition returns the condition of the given type, or nil if not found
+func (s *PodRuleStatus) GetCondition(conditionType PodRuleConditionType) *PodRuleCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the given type, returning whether it changed.
+// LastTransitionTime is only updated when the status changes.
+func (s *PodRuleStatus) SetCondition(conditionType PodRuleConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	existing := s.GetCondition(conditionType)
+	if existing == nil {
+		s.Conditions = append(s.Conditions, PodRuleCondition{
+			Type:               conditionType,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+
+	if existing.Status == status && existing.Reason == reason && existing.Message == message {
+		return false
+	}
+	if existing.Status != status {
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Status = status
+	existing.Reason = reason
+	existing.Message = message
+	return true
+}
@@ -0,0 +1,234 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodRuleSpecActiveAt(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		return t
+	}
+	from := metav1.NewTime(at("2019-03-01T00:00:00Z"))
+	until := metav1.NewTime(at("2019-04-01T00:00:00Z"))
+
+	spec := &PodRuleSpec{ActiveFrom: &from, ActiveUntil: &until}
+
+	active, reason, err := spec.ActiveAt(at("2019-02-01T00:00:00Z"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(active).To(gomega.BeFalse())
+	g.Expect(reason).To(gomega.Equal(ReasonNotYetActive))
+
+	active, _, _ = spec.ActiveAt(at("2019-03-15T00:00:00Z"))
+	g.Expect(active).To(gomega.BeTrue())
+
+	active, reason, _ = spec.ActiveAt(at("2019-04-01T00:00:00Z"))
+	g.Expect(active).To(gomega.BeFalse())
+	g.Expect(reason).To(gomega.Equal(ReasonExpired))
+
+	// nightly maintenance window from 01:00 to 03:00
+	spec = &PodRuleSpec{Schedule: &PodRuleSchedule{
+		Cron:     "0 1 * * *",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}}
+
+	active, _, _ = spec.ActiveAt(at("2019-03-15T02:30:00Z"))
+	g.Expect(active).To(gomega.BeTrue())
+	next, err := spec.NextTransitionAfter(at("2019-03-15T02:30:00Z"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(*next).To(gomega.BeTemporally("==", at("2019-03-15T03:00:00Z")))
+
+	active, reason, _ = spec.ActiveAt(at("2019-03-15T03:00:00Z"))
+	g.Expect(active).To(gomega.BeFalse())
+	g.Expect(reason).To(gomega.Equal(ReasonOutsideSchedule))
+	next, _ = spec.NextTransitionAfter(at("2019-03-15T03:00:00Z"))
+	g.Expect(*next).To(gomega.BeTemporally("==", at("2019-03-16T01:00:00Z")))
+
+	spec.Schedule.Cron = "not a cron"
+	_, reason, err = spec.ActiveAt(at("2019-03-15T03:00:00Z"))
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(reason).To(gomega.Equal(ReasonInvalidSchedule))
+}
+
+func TestPodRuleSpecSelectsNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"env": "production"}}}
+
+	spec := &PodRuleSpec{}
+	g.Expect(spec.SelectsNamespace(namespace)).To(gomega.BeTrue())
+
+	spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}}
+	g.Expect(spec.SelectsNamespace(namespace)).To(gomega.BeTrue())
+
+	spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key:      "env",
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   []string{"production"},
+	}}}
+	g.Expect(spec.SelectsNamespace(namespace)).To(gomega.BeFalse())
+
+	spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}}}
+	_, err := spec.SelectsNamespace(namespace)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestPodMutationsMerge(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mutations := &PodMutations{
+		Labels:           map[string]string{"tier": "app", "team": "core"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockerhub-creds"}},
+		Tolerations: []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "app"},
+		},
+		ImageRegistryRewrites: []ImageRegistryRewrite{
+			{From: "docker.io/", To: "registry.internal/dockerhub/"},
+		},
+		Strategies: PodMutationStrategies{Labels: MutationStrategyKeepExisting},
+	}
+	mutations.Merge(&PodMutations{
+		Labels:       map[string]string{"tier": "web"},
+		NodeSelector: map[string]string{"kubernetes.io/role": "web"},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: "dockerhub-creds"},
+			{Name: "quay-creds"},
+		},
+		Tolerations: []corev1.Toleration{
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "app"},
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "web"},
+		},
+		ImageRegistryRewrites: []ImageRegistryRewrite{
+			{From: "docker.io/", To: "mirror.internal/dockerhub/"},
+			{From: "quay.io/", To: "mirror.internal/quay/"},
+		},
+		Strategies: PodMutationStrategies{NodeSelector: MutationStrategyOverride},
+	})
+
+	g.Expect(mutations.Labels).To(gomega.Equal(map[string]string{"tier": "web", "team": "core"}))
+	g.Expect(mutations.NodeSelector).To(gomega.Equal(map[string]string{"kubernetes.io/role": "web"}))
+	g.Expect(mutations.ImagePullSecrets).To(gomega.Equal([]corev1.LocalObjectReference{
+		{Name: "dockerhub-creds"},
+		{Name: "quay-creds"},
+	}))
+	g.Expect(mutations.Tolerations).To(gomega.HaveLen(2))
+	g.Expect(mutations.ImageRegistryRewrites).To(gomega.Equal([]ImageRegistryRewrite{
+		{From: "docker.io/", To: "mirror.internal/dockerhub/"},
+		{From: "quay.io/", To: "mirror.internal/quay/"},
+	}))
+	g.Expect(mutations.Strategies).To(gomega.Equal(PodMutationStrategies{
+		Labels:       MutationStrategyKeepExisting,
+		NodeSelector: MutationStrategyOverride,
+	}))
+}
+
+func TestMergePodDNSConfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ndots := func(value string) corev1.PodDNSConfigOption {
+		return corev1.PodDNSConfigOption{Name: "ndots", Value: &value}
+	}
+
+	existing := &corev1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10"},
+		Options:     []corev1.PodDNSConfigOption{ndots("5")},
+	}
+	merged := MergePodDNSConfig(existing, &corev1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10", "10.0.0.11"},
+		Searches:    []string{"staging.svc.cluster.local"},
+		Options:     []corev1.PodDNSConfigOption{ndots("2"), {Name: "edns0"}},
+	})
+
+	g.Expect(merged).To(gomega.Equal(&corev1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10", "10.0.0.11"},
+		Searches:    []string{"staging.svc.cluster.local"},
+		Options:     []corev1.PodDNSConfigOption{ndots("2"), {Name: "edns0"}},
+	}))
+	g.Expect(existing.Options).To(gomega.Equal([]corev1.PodDNSConfigOption{ndots("5")}))
+	g.Expect(MergePodDNSConfig(existing, nil)).To(gomega.BeIdenticalTo(existing))
+}
+
+func TestMergeHostAliases(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	merged := MergeHostAliases(
+		[]corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"legacy"}}},
+		[]corev1.HostAlias{
+			{IP: "10.0.0.1", Hostnames: []string{"legacy", "legacy.example.com"}},
+			{IP: "10.0.0.2", Hostnames: []string{"billing"}},
+		},
+	)
+	g.Expect(merged).To(gomega.Equal([]corev1.HostAlias{
+		{IP: "10.0.0.1", Hostnames: []string{"legacy", "legacy.example.com"}},
+		{IP: "10.0.0.2", Hostnames: []string{"billing"}},
+	}))
+}
+
+func TestMergeReadinessGates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	existing := []corev1.PodReadinessGate{{ConditionType: "example.com/registered"}}
+	merged := MergeReadinessGates(existing, []corev1.PodReadinessGate{
+		{ConditionType: "example.com/registered"},
+		{ConditionType: "example.com/warmed-up"},
+	})
+	g.Expect(merged).To(gomega.Equal([]corev1.PodReadinessGate{
+		{ConditionType: "example.com/registered"},
+		{ConditionType: "example.com/warmed-up"},
+	}))
+	g.Expect(existing).To(gomega.HaveLen(1))
+	g.Expect(MergeReadinessGates(existing, nil)).To(gomega.Equal(existing))
+}
+
+func TestMergeVolumes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	configMap := func(name string) corev1.VolumeSource {
+		return corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		}}
+	}
+
+	volumes := MergeVolumes(
+		[]corev1.Volume{{Name: "ca-bundle", VolumeSource: configMap("app-ca")}},
+		[]corev1.Volume{
+			{Name: "ca-bundle", VolumeSource: configMap("cluster-ca")},
+			{Name: "dns-socket", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/dns"}}},
+		},
+	)
+	g.Expect(volumes).To(gomega.HaveLen(2))
+	g.Expect(volumes[0].ConfigMap.Name).To(gomega.Equal("app-ca"))
+	g.Expect(volumes[1].Name).To(gomega.Equal("dns-socket"))
+
+	mounts := MergeVolumeMounts(
+		[]corev1.VolumeMount{{Name: "certs", MountPath: "/etc/ssl/certs"}},
+		[]corev1.VolumeMount{
+			{Name: "ca-bundle", MountPath: "/etc/ssl/certs"},
+			{Name: "dns-socket", MountPath: "/var/run/dns"},
+		},
+	)
+	g.Expect(mounts).To(gomega.Equal([]corev1.VolumeMount{
+		{Name: "certs", MountPath: "/etc/ssl/certs"},
+		{Name: "dns-socket", MountPath: "/var/run/dns"},
+	}))
+}
+
+func TestAppliedRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mutations := &PodMutations{Labels: map[string]string{"tier": "app", "team": "core"}}
+	hash := mutations.Hash()
+	g.Expect(hash).To(gomega.HaveLen(8))
+	g.Expect(mutations.DeepCopy().Hash()).To(gomega.Equal(hash))
+	g.Expect((&PodMutations{Labels: map[string]string{"tier": "web"}}).Hash()).NotTo(gomega.Equal(hash))
+
+	applied := map[string]string{"web": hash, "base": "0123abcd"}
+	value := FormatAppliedRules(applied)
+	g.Expect(value).To(gomega.Equal("base=0123abcd,web=" + hash))
+	g.Expect(ParseAppliedRules(value)).To(gomega.Equal(applied))
+	g.Expect(ParseAppliedRules("")).To(gomega.BeEmpty())
+}
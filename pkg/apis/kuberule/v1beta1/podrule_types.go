@@ -0,0 +1,530 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// PodMutations defines mutations to be applied on the selected pods
+type PodMutations struct {
+
+	// Annotations to be merged with selected pods' existing annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels to be merged with selected pods' existing labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// If specified, the pod's scheduling constraints
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// NodeSelector to be added to selected pods
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// ImagePullSecrets to be added to selected pods
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// If specified, the pod's tolerations.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// ServiceAccountName to be set on selected pods.
+	// The ServiceAccount must exist in the namespace of the pods.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Whether a service account token is automatically mounted on selected pods
+	// +optional
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+
+	// DNSPolicy to be set on selected pods
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig to be added to selected pods
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// HostAliases to be added to selected pods
+	// +optional
+	// +patchMergeKey=ip
+	// +patchStrategy=merge
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// TerminationGracePeriodSeconds to be set on selected pods
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// PreStop hook added to the selected containers not having one
+	// +optional
+	PreStop *ContainerPreStop `json:"preStop,omitempty"`
+
+	// ReadinessGates to be added to selected pods
+	// +optional
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+
+	// ImageRegistryRewrites applied to the images of the selected containers,
+	// e.g. pulling images from a mirror in air-gapped clusters
+	// +optional
+	ImageRegistryRewrites []ImageRegistryRewrite `json:"imageRegistryRewrites,omitempty"`
+
+	// ImagePullPolicy to be set on the selected containers
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Volumes to be added to selected pods, existing volumes with the same name are kept.
+	// Only volume sources allowed by the kube-rule configuration can be used.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts to be added to the selected containers, existing mounts with the same name or path are kept
+	// +optional
+	// +patchMergeKey=mountPath
+	// +patchStrategy=merge
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Containers the container-level mutations apply to, all containers of selected pods if not set
+	// +optional
+	Containers *ContainerSelector `json:"containers,omitempty"`
+
+	// How each of the above mutations is applied on the selected pods
+	// +optional
+	Strategies PodMutationStrategies `json:"strategies,omitempty"`
+}
+
+// ContainerType is the type of the containers of a pod
+type ContainerType string
+
+const (
+	// ContainerTypeInit selects init containers
+	ContainerTypeInit ContainerType = "Init"
+
+	// ContainerTypeRegular selects regular containers
+	ContainerTypeRegular ContainerType = "Regular"
+)
+
+// ContainerSelector selects containers of a pod, all criteria must match.
+// Patterns are globs as in path.Match, e.g. app-* or */istio/proxyv2:*.
+type ContainerSelector struct {
+	// Names or name patterns of the selected containers, all containers if empty
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// Names or name patterns of the containers never selected, e.g. istio-proxy
+	// +optional
+	ExcludeNames []string `json:"excludeNames,omitempty"`
+
+	// Image patterns of the selected containers, matched against the image as written in the pod
+	// and its fully qualified name, e.g. docker.io/library/nginx:1.15 for nginx:1.15
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// Types of the selected containers, Init or Regular, all types if empty
+	// +optional
+	Types []ContainerType `json:"types,omitempty"`
+}
+
+// ContainerPreStop defines a preStop hook added to containers
+type ContainerPreStop struct {
+	// Names of the containers the hook is added to, all selected containers if empty
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+
+	// Handler called before the containers are stopped, e.g. a sleep letting load balancers deregister the pods
+	Handler corev1.Handler `json:"handler"`
+}
+
+// ImageRegistryRewrite replaces the prefix of matching container images.
+// Images without registry are considered from docker.io, e.g. nginx is matched as docker.io/library/nginx.
+type ImageRegistryRewrite struct {
+	// Prefix of the image names to rewrite, e.g. docker.io/ or quay.io/prometheus/.
+	// The longest matching prefix wins, tags and digests are preserved.
+	// +kubebuilder:validation:MinLength=1
+	From string `json:"from"`
+
+	// Replacement of the prefix, e.g. registry.internal/dockerhub/
+	// +kubebuilder:validation:MinLength=1
+	To string `json:"to"`
+}
+
+// MutationStrategy defines how a mutation is applied on a pod field
+type MutationStrategy string
+
+const (
+	// MutationStrategyKeepExisting only sets the field when the pod doesn't have it
+	MutationStrategyKeepExisting MutationStrategy = "KeepExisting"
+
+	// MutationStrategyMerge merges with the pod field: rule values win for maps, missing items are appended to lists
+	MutationStrategyMerge MutationStrategy = "Merge"
+
+	// MutationStrategyOverride replaces the pod field
+	MutationStrategyOverride MutationStrategy = "Override"
+)
+
+// PodMutationStrategies defines how each mutation is applied on the selected pods
+type PodMutationStrategies struct {
+	// Defaults to Merge
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	Annotations MutationStrategy `json:"annotations,omitempty"`
+
+	// Defaults to Merge
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	Labels MutationStrategy `json:"labels,omitempty"`
+
+	// Defaults to KeepExisting, Merge is not supported
+	// +kubebuilder:validation:Enum=KeepExisting;Override
+	// +optional
+	Affinity MutationStrategy `json:"affinity,omitempty"`
+
+	// Defaults to KeepExisting
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	NodeSelector MutationStrategy `json:"nodeSelector,omitempty"`
+
+	// Defaults to Merge
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	ImagePullSecrets MutationStrategy `json:"imagePullSecrets,omitempty"`
+
+	// Defaults to Merge. Always Merge on pod updates, since existing tolerations can't be removed.
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	Tolerations MutationStrategy `json:"tolerations,omitempty"`
+
+	// Defaults to KeepExisting, Merge is not supported.
+	// Pods using the default service account are considered not having one.
+	// +kubebuilder:validation:Enum=KeepExisting;Override
+	// +optional
+	ServiceAccountName MutationStrategy `json:"serviceAccountName,omitempty"`
+
+	// Defaults to KeepExisting, Merge is not supported
+	// +kubebuilder:validation:Enum=KeepExisting;Override
+	// +optional
+	AutomountServiceAccountToken MutationStrategy `json:"automountServiceAccountToken,omitempty"`
+
+	// Defaults to KeepExisting, Merge is not supported.
+	// Pods using the ClusterFirst DNS policy are considered not having one.
+	// +kubebuilder:validation:Enum=KeepExisting;Override
+	// +optional
+	DNSPolicy MutationStrategy `json:"dnsPolicy,omitempty"`
+
+	// Defaults to Merge: missing nameservers and searches are appended, options are set by name
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	DNSConfig MutationStrategy `json:"dnsConfig,omitempty"`
+
+	// Defaults to Merge: missing aliases are appended, hostnames of existing IPs are merged
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	HostAliases MutationStrategy `json:"hostAliases,omitempty"`
+
+	// Defaults to Merge: the greatest of the pod and rule periods is kept.
+	// Pods using the default period of 30 seconds are considered not having one.
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	TerminationGracePeriodSeconds MutationStrategy `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// Defaults to Merge
+	// +kubebuilder:validation:Enum=KeepExisting;Merge;Override
+	// +optional
+	ReadinessGates MutationStrategy `json:"readinessGates,omitempty"`
+
+	// Defaults to KeepExisting, Merge is not supported.
+	// Containers using the policy defaulted from their image tag are considered not having one.
+	// +kubebuilder:validation:Enum=KeepExisting;Override
+	// +optional
+	ImagePullPolicy MutationStrategy `json:"imagePullPolicy,omitempty"`
+}
+
+// PodValidations defines constraints the selected pods must satisfy after mutations
+type PodValidations struct {
+	// Labels which must be set on selected pods
+	// +optional
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+
+	// Annotations which must be set on selected pods
+	// +optional
+	RequiredAnnotations []string `json:"requiredAnnotations,omitempty"`
+
+	// Taints selected pods must not tolerate
+	// +optional
+	MustNotTolerate []corev1.Taint `json:"mustNotTolerate,omitempty"`
+}
+
+// PodRuleMode defines how a rule treats the selected pods
+type PodRuleMode string
+
+const (
+	// PodRuleModeEnforce applies the mutations and validations on the selected pods
+	PodRuleModeEnforce PodRuleMode = "Enforce"
+
+	// PodRuleModeAudit only records the mutations that would have been applied, and the validations
+	// that would have failed, on the selected pods
+	PodRuleModeAudit PodRuleMode = "Audit"
+
+	// PodRuleModeDisabled ignores the rule
+	PodRuleModeDisabled PodRuleMode = "Disabled"
+)
+
+// PodRuleRollout defines a gradual rollout of a rule
+type PodRuleRollout struct {
+	// Percentage of the selected pods the rule is applied on.
+	// Pods of the same owner (e.g. ReplicaSet) always get the same decision.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int32 `json:"percentage"`
+}
+
+// PodRuleSchedule defines recurring windows during which a rule is active
+type PodRuleSchedule struct {
+	// Standard 5 fields cron expression, in UTC, of the windows start
+	Cron string `json:"cron"`
+
+	// Duration of each window, e.g. "2h"
+	Duration metav1.Duration `json:"duration"`
+}
+
+// PodRuleRestart defines how the workloads running pods not up to date with a rule are restarted.
+// Deployments, StatefulSets and DaemonSets are restarted one at a time.
+type PodRuleRestart struct {
+	// Minimum interval between two workload restarts triggered by the rule. Defaults to 10m.
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+}
+
+// PodRuleDeletion defines what happens to the pods mutated by a rule when it is deleted
+type PodRuleDeletion struct {
+	// Remove the labels and annotations added by the rule from the running pods.
+	// Values changed since, set by other rules, or selecting the pods for their workload are kept.
+	// Other mutations can't be reverted on running pods, they are kept until the pods are recreated.
+	// +optional
+	StripMetadata bool `json:"stripMetadata,omitempty"`
+}
+
+// PodMutationProfileKind is the kind of a referenced mutation profile
+type PodMutationProfileKind string
+
+const (
+	// PodMutationProfileKindNamespaced references a PodMutationProfile of the rule's namespace
+	PodMutationProfileKindNamespaced PodMutationProfileKind = "PodMutationProfile"
+
+	// PodMutationProfileKindCluster references a ClusterPodMutationProfile
+	PodMutationProfileKindCluster PodMutationProfileKind = "ClusterPodMutationProfile"
+)
+
+// PodMutationProfileReference references a mutation profile by name
+type PodMutationProfileReference struct {
+	// Kind of the profile: PodMutationProfile or ClusterPodMutationProfile. Defaults to PodMutationProfile.
+	// +kubebuilder:validation:Enum=PodMutationProfile;ClusterPodMutationProfile
+	// +optional
+	Kind PodMutationProfileKind `json:"kind,omitempty"`
+
+	// Name of the profile
+	Name string `json:"name"`
+}
+
+// SecretSourceReference references a secret of any namespace
+type SecretSourceReference struct {
+	// Namespace of the secret
+	Namespace string `json:"namespace"`
+
+	// Name of the secret, also used for its copies
+	Name string `json:"name"`
+}
+
+// PodRuleSpec defines the desired state of PodRule
+type PodRuleSpec struct {
+	// Arbitrary number to define ordering of multiple rules matching same pods.
+	// Higher number will be applied later, but might override mutations of smaller number.
+	// Defaults to 0.
+	// +optional
+	ApplyOrder int32 `json:"applyOrder"`
+
+	// Label selector for pods
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Must be true for an empty selector, explicitly selecting all pods of the namespace
+	// +optional
+	MatchAll bool `json:"matchAll,omitempty"`
+
+	// If specified, the rule only applies while the labels of its namespace match this selector
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Mutation profiles applied on the selected pods, in order, before the inline mutations.
+	// Later profiles and the inline mutations win over earlier ones.
+	// +optional
+	Profiles []PodMutationProfileReference `json:"profiles,omitempty"`
+
+	// Mutations to be done on the selected pods
+	Mutations PodMutations `json:"mutations,omitempty"`
+
+	// Secrets copied into the namespace of the rule, and kept in sync, so the image pull secrets
+	// added by the rule exist. Source secrets must be annotated with kuberule.chickenzord.com/replicable: "true".
+	// +optional
+	ImagePullSecretSources []SecretSourceReference `json:"imagePullSecretSources,omitempty"`
+
+	// Constraints checked on the selected pods, pods violating them are denied
+	// +optional
+	Validations *PodValidations `json:"validations,omitempty"`
+
+	// How the rule is applied: Enforce, Audit or Disabled. Defaults to Enforce.
+	// +kubebuilder:validation:Enum=Enforce;Audit;Disabled
+	// +optional
+	Mode PodRuleMode `json:"mode,omitempty"`
+
+	// If specified, only applies the rule on a part of the selected pods
+	// +optional
+	Rollout *PodRuleRollout `json:"rollout,omitempty"`
+
+	// If specified, the rule is not applied before this time
+	// +optional
+	ActiveFrom *metav1.Time `json:"activeFrom,omitempty"`
+
+	// If specified, the rule is not applied anymore from this time
+	// +optional
+	ActiveUntil *metav1.Time `json:"activeUntil,omitempty"`
+
+	// If specified, the rule is only applied during the scheduled windows
+	// +optional
+	Schedule *PodRuleSchedule `json:"schedule,omitempty"`
+
+	// If specified, the workloads running pods not up to date with the rule are restarted
+	// +optional
+	Restart *PodRuleRestart `json:"restart,omitempty"`
+
+	// If specified, defines how the pods mutated by the rule are cleaned up when it is deleted
+	// +optional
+	OnDeletion *PodRuleDeletion `json:"onDeletion,omitempty"`
+}
+
+// PodRuleAudit records mutations an audit mode rule would have applied on a pod
+type PodRuleAudit struct {
+	// Name (or generateName) of the audited pod
+	Pod string `json:"pod"`
+
+	// Admission operation of the audited pod
+	Operation string `json:"operation"`
+
+	// JSON patch that would have been applied
+	Patch string `json:"patch"`
+
+	// Time of the audit
+	Time metav1.Time `json:"time"`
+}
+
+// PodRuleConditionType is a valid value for PodRuleCondition.Type
+type PodRuleConditionType string
+
+const (
+	// PodRuleActive means the rule is currently applied on pods, see activeFrom, activeUntil and schedule
+	PodRuleActive PodRuleConditionType = "Active"
+
+	// PodRuleProfilesResolved means all the mutation profiles referenced by the rule exist
+	PodRuleProfilesResolved PodRuleConditionType = "ProfilesResolved"
+
+	// PodRuleImagePullSecretsReplicated means all the imagePullSecretSources are copied into the namespace of the rule
+	PodRuleImagePullSecretsReplicated PodRuleConditionType = "ImagePullSecretsReplicated"
+)
+
+// PodRuleCondition contains details for the current condition of a rule
+type PodRuleCondition struct {
+	// Type of the condition
+	Type PodRuleConditionType `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Last time the condition transitioned from one status to another
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Unique, one-word, CamelCase reason for the condition's last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Human-readable message indicating details about last transition
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PodRuleStatus defines the observed state of PodRule
+type PodRuleStatus struct {
+	// Current conditions of the rule
+	// +optional
+	Conditions []PodRuleCondition `json:"conditions,omitempty"`
+
+	// Number of existing pods in the namespace selected by the rule
+	// +optional
+	MatchedPods int32 `json:"matchedPods"`
+
+	// Number of pods audited while in audit mode
+	// +optional
+	AuditedPods int64 `json:"auditedPods,omitempty"`
+
+	// Most recent audits, newest first
+	// +optional
+	LastAudits []PodRuleAudit `json:"lastAudits,omitempty"`
+
+	// Number of pods created before the current version of the rule, or of its profiles
+	// +optional
+	DriftedPods int32 `json:"driftedPods,omitempty"`
+
+	// Workloads running drifted pods, e.g. Deployment/web, limited to the first ones
+	// +optional
+	DriftedWorkloads []string `json:"driftedWorkloads,omitempty"`
+
+	// Last time a workload was restarted by the rule
+	// +optional
+	LastRestartTime *metav1.Time `json:"lastRestartTime,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodRule is the Schema for the podrules API
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=podrules,shortName=pr
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Order",type="integer",JSONPath=".spec.applyOrder",description="Order in which the rule is applied"
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode",description="How the rule is applied"
+// +kubebuilder:printcolumn:name="Matched",type="integer",JSONPath=".status.matchedPods",description="Number of pods selected by the rule"
+// +kubebuilder:printcolumn:name="Drifted",type="integer",JSONPath=".status.driftedPods",description="Number of pods not up to date with the rule"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type PodRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodRuleSpec   `json:"spec,omitempty"`
+	Status PodRuleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodRuleList contains a list of PodRule
+type PodRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodRule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodRule{}, &PodRuleList{})
+}
@@ -0,0 +1,65 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodRulePolicySpec defines restrictions on the PodRules of the selected namespaces.
+// Empty lists don't restrict anything.
+type PodRulePolicySpec struct {
+	// Label selector for namespaces whose PodRules are restricted.
+	// If not specified, all namespaces are selected.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Mutations PodRules may use, by field name (e.g. annotations, tolerations)
+	// +optional
+	AllowedMutations []string `json:"allowedMutations,omitempty"`
+
+	// Prefixes of annotation keys PodRules may set
+	// +optional
+	AllowedAnnotationPrefixes []string `json:"allowedAnnotationPrefixes,omitempty"`
+
+	// Prefixes of label keys PodRules may set
+	// +optional
+	AllowedLabelPrefixes []string `json:"allowedLabelPrefixes,omitempty"`
+
+	// Toleration keys PodRules may add
+	// +optional
+	AllowedTolerationKeys []string `json:"allowedTolerationKeys,omitempty"`
+
+	// Node label keys PodRules may use in nodeSelector and node affinity
+	// +optional
+	AllowedNodeSelectorKeys []string `json:"allowedNodeSelectorKeys,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodRulePolicy is the Schema for the podrulepolicies API.
+// It allows cluster admins to restrict what namespaced PodRules may set.
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=podrulepolicies,scope=Cluster,shortName=prp
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type PodRulePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodRulePolicySpec `json:"spec,omitempty"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodRulePolicyList contains a list of PodRulePolicy
+type PodRulePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodRulePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodRulePolicy{}, &PodRulePolicyList{})
+}
@@ -5,22 +5,53 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
 // PREFIX is config prefix
 const PREFIX = "kuberule"
 
+// NamespaceIgnoreLabel set to "true" on a namespace excludes it from the default webhook namespace selector
+const NamespaceIgnoreLabel = "kuberule.chickenzord.com/ignore"
+
+// WebhookSettings are the admission settings of a webhook, set in the webhook configurations
+type WebhookSettings struct {
+	FailurePolicy     string
+	TimeoutSeconds    int
+	SideEffects       string
+	ObjectSelector    *metav1.LabelSelector
+	NamespaceSelector *metav1.LabelSelector
+}
+
 var (
 	AppName         string
 	PodNamespace    string
 	Namespace       string
-	CertDir         string
 	SecretName      string
 	ServiceName     string
 	ServiceSelector labels.Set
+
+	WebhookPort     int
+	CertProvider    string
+	CertValidity    time.Duration
+	CertCAValidity  time.Duration
+	CertRenewBefore time.Duration
+
+	MutatePodsWebhook                  WebhookSettings
+	ValidatePodsWebhook                WebhookSettings
+	MutatePodRulesWebhook              WebhookSettings
+	ValidatePodRulesWebhook            WebhookSettings
+	ValidatePodMutationProfilesWebhook WebhookSettings
+
+	PodsReinvocationPolicy      string
+	PodsOptIn                   bool
+	PodsAllowedVolumeSources    []string
+	PodsAllowedHostPathPrefixes []string
 )
 
 func init() {
@@ -49,9 +80,6 @@ func Initialize() {
 	viper.SetDefault("namespace", PodNamespace)
 	Namespace = viper.GetString("namespace")
 
-	viper.SetDefault("cert.dir", "/tmp/cert")
-	CertDir = viper.GetString("cert.dir")
-
 	viper.SetDefault("service.name", AppName)
 	ServiceName = viper.GetString("service.name")
 
@@ -66,6 +94,120 @@ func Initialize() {
 	} else {
 		ServiceSelector = selector
 	}
+
+	viper.SetDefault("webhook.port", 9876)
+	WebhookPort = viper.GetInt("webhook.port")
+
+	// certificates are issued by kuberule, or by cert-manager into the secret
+	viper.SetDefault("cert.provider", "self")
+	CertProvider = viper.GetString("cert.provider")
+
+	switch CertProvider {
+	case "self", "cert-manager":
+	default:
+		panic(fmt.Errorf("cert.provider=\"%s\"\nmust be one of self, cert-manager", CertProvider))
+	}
+
+	viper.SetDefault("cert.validity", "2160h")
+	CertValidity = viper.GetDuration("cert.validity")
+
+	viper.SetDefault("cert.ca_validity", "87600h")
+	CertCAValidity = viper.GetDuration("cert.ca_validity")
+
+	viper.SetDefault("cert.renew_before", "720h")
+	CertRenewBefore = viper.GetDuration("cert.renew_before")
+
+	if CertRenewBefore >= CertValidity {
+		panic(fmt.Errorf("cert.renew_before=\"%s\"\nmust be shorter than cert.validity", CertRenewBefore))
+	}
+
+	// pods webhooks record audits, events and metrics, skipped for dry run requests
+	MutatePodsWebhook = webhookSettings("webhook.mutate_pods", "NoneOnDryRun")
+	ValidatePodsWebhook = webhookSettings("webhook.validate_pods", "NoneOnDryRun")
+	MutatePodRulesWebhook = webhookSettings("webhook.mutate_podrules", "None")
+	ValidatePodRulesWebhook = webhookSettings("webhook.validate_podrules", "None")
+	ValidatePodMutationProfilesWebhook = webhookSettings("webhook.validate_podmutationprofiles", "None")
+
+	viper.SetDefault("webhook.pods.reinvocation_policy", "Never")
+	PodsReinvocationPolicy = viper.GetString("webhook.pods.reinvocation_policy")
+
+	switch PodsReinvocationPolicy {
+	case "Never", "IfNeeded":
+	default:
+		panic(fmt.Errorf("webhook.pods.reinvocation_policy=\"%s\"\nmust be one of Never, IfNeeded", PodsReinvocationPolicy))
+	}
+
+	viper.SetDefault("webhook.pods.opt_in", false)
+	PodsOptIn = viper.GetBool("webhook.pods.opt_in")
+
+	// volumes can't be injected unless allowed by cluster admins
+	viper.SetDefault("webhook.pods.volumes.allowed_sources", "")
+	PodsAllowedVolumeSources = splitList(viper.GetString("webhook.pods.volumes.allowed_sources"))
+
+	viper.SetDefault("webhook.pods.volumes.allowed_host_path_prefixes", "")
+	PodsAllowedHostPathPrefixes = splitList(viper.GetString("webhook.pods.volumes.allowed_host_path_prefixes"))
+}
+
+// webhookSettings reads the admission settings of the webhook under the given key
+func webhookSettings(key string, sideEffects string) WebhookSettings {
+	viper.SetDefault(key+".failure_policy", "Ignore")
+	viper.SetDefault(key+".timeout_seconds", 10)
+	viper.SetDefault(key+".side_effects", sideEffects)
+	viper.SetDefault(key+".object_selector", "")
+	// never call the webhooks for kuberule's own namespace, its pods couldn't be created
+	// while kuberule is down with the Fail policy. Namespace name labels are set from Kubernetes 1.21.
+	viper.SetDefault(key+".namespace_selector",
+		fmt.Sprintf("%s notin (true),kubernetes.io/metadata.name notin (%s)", NamespaceIgnoreLabel, Namespace))
+
+	settings := WebhookSettings{
+		FailurePolicy:  viper.GetString(key + ".failure_policy"),
+		TimeoutSeconds: viper.GetInt(key + ".timeout_seconds"),
+		SideEffects:    viper.GetString(key + ".side_effects"),
+	}
+
+	switch settings.FailurePolicy {
+	case "Ignore", "Fail":
+	default:
+		panic(fmt.Errorf("%s.failure_policy=\"%s\"\nmust be one of Ignore, Fail", key, settings.FailurePolicy))
+	}
+
+	if settings.TimeoutSeconds < 1 || settings.TimeoutSeconds > 30 {
+		panic(fmt.Errorf("%s.timeout_seconds=\"%d\"\nmust be between 1 and 30", key, settings.TimeoutSeconds))
+	}
+
+	switch settings.SideEffects {
+	case "None", "NoneOnDryRun", "Some", "Unknown":
+	default:
+		panic(fmt.Errorf("%s.side_effects=\"%s\"\nmust be one of None, NoneOnDryRun, Some, Unknown", key, settings.SideEffects))
+	}
+
+	settings.ObjectSelector = parseSelector(key+".object_selector", viper.GetString(key+".object_selector"))
+	settings.NamespaceSelector = parseSelector(key+".namespace_selector", viper.GetString(key+".namespace_selector"))
+
+	return settings
+}
+
+// parseSelector parses a label selector, e.g. "env in (prod),tier notin (batch)", empty selecting everything
+func parseSelector(key string, value string) *metav1.LabelSelector {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	selector, err := metav1.ParseToLabelSelector(value)
+	if err != nil {
+		panic(fmt.Errorf("%s=\"%s\"\n%s", key, value, err))
+	}
+	return selector
+}
+
+// splitList splits a comma separated list, ignoring empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // Debug returns the entire config map
@@ -0,0 +1,283 @@
+package imagepullsecret
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller.imagepullsecret")
+
+// Add creates a new image pull secret replication Controller and adds it to the Manager.
+// The Manager will set fields on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileImagePullSecret{Client: mgr.GetClient()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("imagepullsecret-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to PodRule
+	err = c.Watch(&source.Kind{Type: &kuberule.PodRule{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to source secrets and their copies
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return secretPodRules(mgr.GetClient(), object.Meta)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// secretPodRules returns reconcile requests for the PodRules having the secret as source, or as copy
+func secretPodRules(c client.Client, secret metav1.Object) []reconcile.Request {
+	annotations := secret.GetAnnotations()
+	if annotations[kuberule.AnnotationReplicable] != "true" && annotations[kuberule.AnnotationReplicatedFrom] == "" {
+		return nil
+	}
+
+	podRuleList := &kuberule.PodRuleList{}
+	if err := c.List(context.TODO(), &client.ListOptions{}, podRuleList); err != nil {
+		log.Error(err, "unable to list podrules")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, rule := range podRuleList.Items {
+		for _, source := range rule.Spec.ImagePullSecretSources {
+			if source.Name != secret.GetName() {
+				continue
+			}
+			if source.Namespace == secret.GetNamespace() || rule.Namespace == secret.GetNamespace() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+var _ reconcile.Reconciler = &ReconcileImagePullSecret{}
+
+// ReconcileImagePullSecret copies the imagePullSecretSources of a PodRule into its namespace
+type ReconcileImagePullSecret struct {
+	client.Client
+}
+
+// Reconcile keeps the copies of the secrets referenced by a PodRule in sync with their sources
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+func (r *ReconcileImagePullSecret) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	// Fetch the PodRule instance
+	instance := &kuberule.PodRule{}
+	err := r.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Object not found, return. Copies are garbage collected through their owner references.
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+	if instance.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	reason := kuberule.ReasonSecretsReplicated
+	problems := []string{}
+	for _, source := range instance.Spec.ImagePullSecretSources {
+		sourceReason, err := r.replicateSecret(instance, source)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if sourceReason != "" {
+			reason = sourceReason
+			problems = append(problems, fmt.Sprintf("%s/%s: %s", source.Namespace, source.Name, sourceReason))
+		}
+	}
+
+	if err := r.releaseSecrets(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// don't report anything on rules never having sources
+	if len(instance.Spec.ImagePullSecretSources) == 0 && instance.Status.GetCondition(kuberule.PodRuleImagePullSecretsReplicated) == nil {
+		return reconcile.Result{}, nil
+	}
+
+	status := corev1.ConditionTrue
+	message := ""
+	if len(problems) > 0 {
+		status = corev1.ConditionFalse
+		message = "Secrets not replicated: " + strings.Join(problems, ", ")
+	}
+	if instance.Status.SetCondition(kuberule.PodRuleImagePullSecretsReplicated, status, reason, message) {
+		log.Info("updating status", "podrule", request.NamespacedName)
+		if err := r.Status().Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// replicateSecret creates or updates the copy of the source secret in the namespace of the rule.
+// Returns the reason the secret can't be replicated, if any.
+func (r *ReconcileImagePullSecret) replicateSecret(instance *kuberule.PodRule, source kuberule.SecretSourceReference) (string, error) {
+	// nothing to copy
+	if source.Namespace == instance.Namespace {
+		return "", nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: source.Namespace, Name: source.Name}, secret)
+	if errors.IsNotFound(err) {
+		return kuberule.ReasonSecretNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// only secrets explicitly shared by their owners are copied
+	if secret.Annotations[kuberule.AnnotationReplicable] != "true" {
+		return kuberule.ReasonSecretNotReplicable, nil
+	}
+
+	replicatedFrom := source.Namespace + "/" + source.Name
+	replica := &corev1.Secret{}
+	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: source.Name}, replica)
+	if errors.IsNotFound(err) {
+		replica = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       instance.Namespace,
+				Name:            source.Name,
+				Annotations:     map[string]string{kuberule.AnnotationReplicatedFrom: replicatedFrom},
+				OwnerReferences: []metav1.OwnerReference{ownerReference(instance)},
+			},
+			Type: secret.Type,
+			Data: secret.Data,
+		}
+		log.Info("creating secret copy", "source", replicatedFrom, "namespace", instance.Namespace)
+		return "", r.Create(context.TODO(), replica)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// never overwrite secrets not created by kuberule
+	if replica.Annotations[kuberule.AnnotationReplicatedFrom] != replicatedFrom {
+		return kuberule.ReasonSecretConflict, nil
+	}
+
+	changed := false
+	if !reflect.DeepEqual(replica.Data, secret.Data) {
+		replica.Data = secret.Data
+		changed = true
+	}
+	if !isOwnedBy(replica, instance) {
+		replica.OwnerReferences = append(replica.OwnerReferences, ownerReference(instance))
+		changed = true
+	}
+	if !changed {
+		return "", nil
+	}
+
+	log.Info("updating secret copy", "source", replicatedFrom, "namespace", instance.Namespace)
+	return "", r.Update(context.TODO(), replica)
+}
+
+// releaseSecrets removes the rule from the owners of the copies it doesn't reference anymore,
+// deleting copies left without owners
+func (r *ReconcileImagePullSecret) releaseSecrets(instance *kuberule.PodRule) error {
+	referenced := map[string]bool{}
+	for _, source := range instance.Spec.ImagePullSecretSources {
+		referenced[source.Name] = true
+	}
+
+	secretList := &corev1.SecretList{}
+	if err := r.List(context.TODO(), client.InNamespace(instance.Namespace), secretList); err != nil {
+		return err
+	}
+
+	for i := range secretList.Items {
+		replica := &secretList.Items[i]
+		if replica.Annotations[kuberule.AnnotationReplicatedFrom] == "" || referenced[replica.Name] || !isOwnedBy(replica, instance) {
+			continue
+		}
+
+		owners := []metav1.OwnerReference{}
+		for _, owner := range replica.OwnerReferences {
+			if owner.UID != instance.UID {
+				owners = append(owners, owner)
+			}
+		}
+
+		if len(owners) == 0 {
+			log.Info("deleting secret copy", "namespace", replica.Namespace, "name", replica.Name)
+			if err := r.Delete(context.TODO(), replica); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+
+		replica.OwnerReferences = owners
+		if err := r.Update(context.TODO(), replica); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ownerReference returns a reference to the rule, copies being shared by the rules referencing them
+func ownerReference(instance *kuberule.PodRule) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: kuberule.SchemeGroupVersion.String(),
+		Kind:       "PodRule",
+		Name:       instance.Name,
+		UID:        instance.UID,
+	}
+}
+
+// isOwnedBy checks whether the rule is one of the owners of the secret
+func isOwnedBy(secret *corev1.Secret, instance *kuberule.PodRule) bool {
+	for _, owner := range secret.OwnerReferences {
+		if owner.UID == instance.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package imagepullsecret
+
+import (
+	"testing"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var c client.Client
+
+const timeout = time.Second * 5
+
+func TestReconcileImagePullSecrets(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "registry-creds",
+			Namespace:   "kube-public",
+			Annotations: map[string]string{kuberule.AnnotationReplicable: "true"},
+		},
+		Data: map[string][]byte{"token": []byte("foo")},
+	}
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			MatchAll: true,
+			Mutations: kuberule.PodMutations{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-creds"}},
+			},
+			ImagePullSecretSources: []kuberule.SecretSourceReference{
+				{Namespace: "kube-public", Name: "registry-creds"},
+				{Namespace: "kube-public", Name: "missing-creds"},
+			},
+		},
+	}
+	ruleKey := types.NamespacedName{Name: "foo", Namespace: "default"}
+	replicaKey := types.NamespacedName{Name: "registry-creds", Namespace: "default"}
+
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	// several reconciles are expected, don't wrap the reconciler as nobody reads the requests
+	g.Expect(add(mgr, newReconciler(mgr))).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	err = c.Create(context.TODO(), source)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), source)
+
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+
+	replicaData := func() string {
+		replica := &corev1.Secret{}
+		if err := c.Get(context.TODO(), replicaKey, replica); err != nil {
+			return ""
+		}
+		return string(replica.Data["token"])
+	}
+
+	// The source secret is copied into the namespace of the rule
+	g.Eventually(replicaData, timeout).Should(gomega.Equal("foo"))
+	defer c.Delete(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: "default"}})
+
+	// Missing sources are reported
+	g.Eventually(func() string {
+		fetched := &kuberule.PodRule{}
+		if err := c.Get(context.TODO(), ruleKey, fetched); err != nil {
+			return ""
+		}
+		if condition := fetched.Status.GetCondition(kuberule.PodRuleImagePullSecretsReplicated); condition != nil {
+			return condition.Reason
+		}
+		return ""
+	}, timeout).Should(gomega.Equal(kuberule.ReasonSecretNotFound))
+
+	// Updating the source updates the copy
+	g.Expect(c.Get(context.TODO(), types.NamespacedName{Name: "registry-creds", Namespace: "kube-public"}, source)).NotTo(gomega.HaveOccurred())
+	source.Data["token"] = []byte("bar")
+	g.Expect(c.Update(context.TODO(), source)).NotTo(gomega.HaveOccurred())
+	g.Eventually(replicaData, timeout).Should(gomega.Equal("bar"))
+}
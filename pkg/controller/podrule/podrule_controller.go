@@ -0,0 +1,282 @@
+package podrule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/config"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller.podrule")
+
+// Add creates a new PodRule Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcilePodRule{
+		Client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetRecorder(config.AppName),
+		now:      time.Now,
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("podrule-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to PodRule
+	err = c.Watch(&source.Kind{Type: &kuberule.PodRule{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Pods, counted and checked for drift by the PodRules of their namespace
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return podrules.NamespaceRequests(mgr.GetClient(), object.Meta.GetNamespace())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to Namespaces, whose labels are matched by the namespaceSelector of their PodRules
+	err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return podrules.NamespaceRequests(mgr.GetClient(), object.Meta.GetName())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to namespaced profiles, referenced by the PodRules of their namespace
+	err = c.Watch(&source.Kind{Type: &kuberule.PodMutationProfile{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return podrules.NamespaceRequests(mgr.GetClient(), object.Meta.GetNamespace())
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to cluster profiles, referenced by PodRules of any namespace
+	err = c.Watch(&source.Kind{Type: &kuberule.ClusterPodMutationProfile{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+			return podrules.NamespaceRequests(mgr.GetClient(), "")
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var _ reconcile.Reconciler = &ReconcilePodRule{}
+
+// ReconcilePodRule reconciles a PodRule object
+type ReconcilePodRule struct {
+	client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	now      func() time.Time
+}
+
+// Reconcile keeps the status of a PodRule up to date, being the only writer of the status
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrules,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podmutationprofiles;clusterpodmutationprofiles,verbs=get;list;watch
+func (r *ReconcilePodRule) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	// Fetch the PodRule instance
+	instance := &kuberule.PodRule{}
+	err := r.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Object not found, return.  Created objects are automatically garbage collected.
+			// For additional cleanup logic use finalizers.
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	// Report the affected workloads then release the finalizer set by the webhook on deletion
+	if instance.DeletionTimestamp != nil {
+		if err := r.finalize(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, r.releaseFinalizer(instance)
+	}
+
+	changed, requeueAfter := r.reconcileActive(instance)
+
+	matchedChanged, err := r.reconcileMatchedPods(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	changed = changed || matchedChanged
+
+	profilesChanged, err := r.reconcileProfiles(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	changed = changed || profilesChanged
+
+	driftChanged, restartAfter, err := r.reconcileDrift(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	changed = changed || driftChanged
+	if restartAfter > 0 && (requeueAfter == 0 || restartAfter < requeueAfter) {
+		requeueAfter = restartAfter
+	}
+
+	if changed {
+		log.Info("updating status", "podrule", request.NamespacedName)
+		if err := r.Status().Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileActive sets the Active condition according to activeFrom, activeUntil and schedule.
+// Returns whether the status changed and when the condition may change next.
+func (r *ReconcilePodRule) reconcileActive(instance *kuberule.PodRule) (bool, time.Duration) {
+	now := r.now()
+
+	active, reason, err := instance.Spec.ActiveAt(now)
+	if err != nil {
+		return instance.Status.SetCondition(kuberule.PodRuleActive, corev1.ConditionFalse, reason, err.Error()), 0
+	}
+
+	status := corev1.ConditionFalse
+	if active {
+		status = corev1.ConditionTrue
+	}
+	var previousStatus corev1.ConditionStatus
+	if previous := instance.Status.GetCondition(kuberule.PodRuleActive); previous != nil {
+		previousStatus = previous.Status
+	}
+	changed := instance.Status.SetCondition(kuberule.PodRuleActive, status, reason, "")
+
+	if previousStatus != "" && previousStatus != status {
+		if active {
+			r.recorder.Event(instance, corev1.EventTypeNormal, "Activated", "Rule is now active")
+		} else {
+			r.recorder.Eventf(instance, corev1.EventTypeNormal, "Deactivated", "Rule is now inactive: %s", reason)
+		}
+	}
+
+	next, err := instance.Spec.NextTransitionAfter(now)
+	if err != nil || next == nil {
+		return changed, 0
+	}
+	// requeue slightly after the transition to be sure it happened
+	return changed, next.Sub(now) + time.Second
+}
+
+// reconcileMatchedPods counts the pods of the namespace selected by the rule.
+// Returns whether the status changed.
+func (r *ReconcilePodRule) reconcileMatchedPods(instance *kuberule.PodRule) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&instance.Spec.Selector)
+	if err != nil {
+		// invalid selectors are rejected by the webhook, nothing to count
+		log.Error(err, "invalid selector", "podrule", instance.Namespace+"/"+instance.Name)
+		return false, nil
+	}
+
+	podList := &corev1.PodList{}
+	listOptions := &client.ListOptions{Namespace: instance.Namespace, LabelSelector: selector}
+	if err := r.List(context.TODO(), listOptions, podList); err != nil {
+		return false, err
+	}
+
+	matched := int32(len(podList.Items))
+	if instance.Status.MatchedPods == matched {
+		return false, nil
+	}
+	instance.Status.MatchedPods = matched
+	return true, nil
+}
+
+// reconcileProfiles sets the ProfilesResolved condition, listing the referenced profiles not found.
+// Returns whether the status changed.
+func (r *ReconcilePodRule) reconcileProfiles(instance *kuberule.PodRule) (bool, error) {
+	missing := []string{}
+	for _, ref := range instance.Spec.Profiles {
+		var err error
+		if ref.Kind == kuberule.PodMutationProfileKindCluster {
+			err = r.Get(context.TODO(), types.NamespacedName{Name: ref.Name}, &kuberule.ClusterPodMutationProfile{})
+		} else {
+			err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: ref.Name}, &kuberule.PodMutationProfile{})
+		}
+		if errors.IsNotFound(err) {
+			kind := ref.Kind
+			if kind == "" {
+				kind = kuberule.PodMutationProfileKindNamespaced
+			}
+			missing = append(missing, fmt.Sprintf("%s %s", kind, ref.Name))
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(missing) > 0 {
+		message := "Referenced profiles not found: " + strings.Join(missing, ", ")
+		return instance.Status.SetCondition(kuberule.PodRuleProfilesResolved, corev1.ConditionFalse, kuberule.ReasonProfileNotFound, message), nil
+	}
+	return instance.Status.SetCondition(kuberule.PodRuleProfilesResolved, corev1.ConditionTrue, kuberule.ReasonProfilesResolved, ""), nil
+}
+
+// releaseFinalizer removes the kuberule finalizer from the rule, letting it be deleted
+func (r *ReconcilePodRule) releaseFinalizer(instance *kuberule.PodRule) error {
+	finalizers := []string{}
+	for _, finalizer := range instance.Finalizers {
+		if finalizer != kuberule.Finalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	if len(finalizers) == len(instance.Finalizers) {
+		return nil
+	}
+
+	log.Info("releasing finalizer", "podrule", instance.Namespace+"/"+instance.Name)
+	instance.Finalizers = finalizers
+	return r.Update(context.TODO(), instance)
+}
@@ -0,0 +1,242 @@
+package podrule
+
+import (
+	"testing"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var c client.Client
+
+var expectedRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+var ruleKey = types.NamespacedName{Name: "foo", Namespace: "default"}
+
+const timeout = time.Second * 5
+
+func TestReconcileActiveCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	expired := metav1.NewTime(time.Now().Add(-time.Hour))
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			ActiveUntil: &expired,
+		},
+	}
+
+	// Setup the Manager and Controller.  Wrap the Controller Reconcile function so it writes each request to a
+	// channel when it is finished.
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	recFn, requests := SetupTestReconcile(newReconciler(mgr))
+	g.Expect(add(mgr, recFn)).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	// Create the PodRule object and expect the Reconcile
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+	g.Eventually(requests, timeout).Should(gomega.Receive(gomega.Equal(expectedRequest)))
+
+	// Expect the rule to be reported as inactive
+	g.Eventually(func() corev1.ConditionStatus {
+		fetched := &kuberule.PodRule{}
+		if err := c.Get(context.TODO(), ruleKey, fetched); err != nil {
+			return ""
+		}
+		if condition := fetched.Status.GetCondition(kuberule.PodRuleActive); condition != nil {
+			return condition.Status
+		}
+		return ""
+	}, timeout).Should(gomega.Equal(corev1.ConditionFalse))
+}
+
+func TestReconcileMatchedPods(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "bar"}},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default", Labels: map[string]string{"app": "bar"}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "bar", Image: "busybox"}},
+		},
+	}
+	key := types.NamespacedName{Name: "bar", Namespace: "default"}
+
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	// several reconciles are expected, don't wrap the reconciler as nobody reads the requests
+	g.Expect(add(mgr, newReconciler(mgr))).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+
+	matchedPods := func() int32 {
+		fetched := &kuberule.PodRule{}
+		if err := c.Get(context.TODO(), key, fetched); err != nil {
+			return -1
+		}
+		return fetched.Status.MatchedPods
+	}
+
+	// Creating a selected pod updates the count
+	err = c.Create(context.TODO(), pod)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), pod)
+	g.Eventually(matchedPods, timeout).Should(gomega.Equal(int32(1)))
+}
+
+func TestReconcileProfiles(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "baz", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			MatchAll: true,
+			Profiles: []kuberule.PodMutationProfileReference{{Name: "baz"}},
+		},
+	}
+	profile := &kuberule.PodMutationProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "baz", Namespace: "default"},
+		Spec: kuberule.PodMutationProfileSpec{
+			Mutations: kuberule.PodMutations{Labels: map[string]string{"tier": "app"}},
+		},
+	}
+	key := types.NamespacedName{Name: "baz", Namespace: "default"}
+
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	// several reconciles are expected, don't wrap the reconciler as nobody reads the requests
+	g.Expect(add(mgr, newReconciler(mgr))).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+
+	profilesResolved := func() string {
+		fetched := &kuberule.PodRule{}
+		if err := c.Get(context.TODO(), key, fetched); err != nil {
+			return ""
+		}
+		if condition := fetched.Status.GetCondition(kuberule.PodRuleProfilesResolved); condition != nil {
+			return condition.Reason
+		}
+		return ""
+	}
+
+	// Missing profiles are reported
+	g.Eventually(profilesResolved, timeout).Should(gomega.Equal(kuberule.ReasonProfileNotFound))
+
+	// Creating the profile resolves the rule
+	err = c.Create(context.TODO(), profile)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), profile)
+	g.Eventually(profilesResolved, timeout).Should(gomega.Equal(kuberule.ReasonProfilesResolved))
+}
+
+func TestReconcileDeletion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "qux",
+			Namespace:  "default",
+			Finalizers: []string{kuberule.Finalizer},
+		},
+		Spec: kuberule.PodRuleSpec{
+			MatchAll: true,
+			Mutations: kuberule.PodMutations{
+				Labels:      map[string]string{"team": "platform", "tier": "app"},
+				Annotations: map[string]string{"example.com/owner": "platform"},
+			},
+			OnDeletion: &kuberule.PodRuleDeletion{StripMetadata: true},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "qux",
+			Namespace: "default",
+			// tier was changed since the pod creation, it is kept
+			Labels: map[string]string{"app": "qux", "team": "platform", "tier": "web"},
+			Annotations: map[string]string{
+				"example.com/owner":             "platform",
+				kuberule.AnnotationAppliedRules: "qux=00000000",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "qux", Image: "busybox"}},
+		},
+	}
+	key := types.NamespacedName{Name: "qux", Namespace: "default"}
+
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	// several reconciles are expected, don't wrap the reconciler as nobody reads the requests
+	g.Expect(add(mgr, newReconciler(mgr))).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	err = c.Create(context.TODO(), pod)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), pod)
+
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+
+	// Deleting the rule releases it once the pods are cleaned up
+	g.Expect(c.Delete(context.TODO(), instance)).NotTo(gomega.HaveOccurred())
+	g.Eventually(func() bool {
+		return errors.IsNotFound(c.Get(context.TODO(), key, &kuberule.PodRule{}))
+	}, timeout).Should(gomega.BeTrue())
+
+	fetched := &corev1.Pod{}
+	g.Expect(c.Get(context.TODO(), key, fetched)).NotTo(gomega.HaveOccurred())
+	g.Expect(fetched.Labels).To(gomega.Equal(map[string]string{"app": "qux", "tier": "web"}))
+	g.Expect(fetched.Annotations).To(gomega.BeEmpty())
+}
@@ -0,0 +1,203 @@
+package podrule
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxDriftedWorkloads limits the workloads listed in the rule status
+const maxDriftedWorkloads = 10
+
+// reconcileDrift reports the pods not up to date with the current mutations of the rule,
+// restarting their workloads if asked to. Returns whether the status changed and when to check the workloads again.
+func (r *ReconcilePodRule) reconcileDrift(instance *kuberule.PodRule) (bool, time.Duration, error) {
+	mutations, err := podrules.ResolveMutations(context.TODO(), r.Client, instance)
+	if errors.IsNotFound(err) {
+		// the rule isn't applied, missing profiles are reported by the ProfilesResolved condition
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: instance.Namespace}, namespace); err != nil {
+		return false, 0, err
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(context.TODO(), client.InNamespace(instance.Namespace), podList); err != nil {
+		return false, 0, err
+	}
+
+	hash := mutations.Hash()
+	driftedPods := int32(0)
+	workloads := sets.NewString()
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp != nil || !podDrifted(instance, hash, pod, namespace) {
+			continue
+		}
+		driftedPods++
+		workload, err := podrules.PodWorkload(context.TODO(), r.Client, pod)
+		if err != nil {
+			return false, 0, err
+		}
+		workloads.Insert(workload)
+	}
+
+	previous := instance.Status.DeepCopy()
+	instance.Status.DriftedPods = driftedPods
+	instance.Status.DriftedWorkloads = nil
+	for _, workload := range workloads.List() {
+		if len(instance.Status.DriftedWorkloads) == maxDriftedWorkloads {
+			break
+		}
+		instance.Status.DriftedWorkloads = append(instance.Status.DriftedWorkloads, workload)
+	}
+
+	requeueAfter := time.Duration(0)
+	if instance.Spec.Restart != nil && workloads.Len() > 0 {
+		if requeueAfter, err = r.restartWorkload(instance, workloads.List()); err != nil {
+			return false, 0, err
+		}
+	}
+
+	return !reflect.DeepEqual(previous, &instance.Status), requeueAfter, nil
+}
+
+// podDrifted checks whether the pod was created with other mutations than the current ones of the rule.
+// Pods without record of applied rules, e.g. skipped ones, never drift.
+func podDrifted(rule *kuberule.PodRule, hash string, pod *corev1.Pod, namespace *corev1.Namespace) bool {
+	value, recorded := pod.Annotations[kuberule.AnnotationAppliedRules]
+	if !recorded {
+		return false
+	}
+	appliedHash, applied := kuberule.ParseAppliedRules(value)[rule.Name]
+
+	enforced := rule.Spec.Mode == "" || rule.Spec.Mode == kuberule.PodRuleModeEnforce
+	selector, err := metav1.LabelSelectorAsSelector(&rule.Spec.Selector)
+	if err != nil {
+		return false
+	}
+	namespaceSelected, err := rule.Spec.SelectsNamespace(namespace)
+	if err != nil {
+		return false
+	}
+	excluded := podrules.ExcludedRules(pod, namespace).Has(rule.Name)
+	selected := enforced && namespaceSelected && selector.Matches(labels.Set(pod.Labels)) && !excluded
+
+	if applied {
+		return !selected || appliedHash != hash
+	}
+
+	// rules applied on part of the pods or at some times don't have to be applied on all selected pods
+	partial := rule.Spec.Rollout != nil || rule.Spec.Schedule != nil || rule.Spec.ActiveFrom != nil || rule.Spec.ActiveUntil != nil
+	return selected && !partial
+}
+
+// restartWorkload restarts the first drifted workload not already rolling out, at most once per interval.
+// Returns when to check the workloads again.
+func (r *ReconcilePodRule) restartWorkload(instance *kuberule.PodRule, workloads []string) (time.Duration, error) {
+	now := r.now()
+	interval := instance.Spec.Restart.MinIntervalOrDefault()
+	if last := instance.Status.LastRestartTime; last != nil && now.Sub(last.Time) < interval {
+		return last.Add(interval).Sub(now), nil
+	}
+
+	for _, workload := range workloads {
+		parts := strings.SplitN(workload, "/", 2)
+		restarted, err := r.restartTemplate(instance.Namespace, parts[0], parts[1], now)
+		if err != nil {
+			return 0, err
+		}
+		if !restarted {
+			continue
+		}
+
+		log.Info("restarted drifted workload", "podrule", instance.Namespace+"/"+instance.Name, "workload", workload)
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, "Restarted", "Restarted %s running pods not up to date with the rule", workload)
+		instance.Status.LastRestartTime = &metav1.Time{Time: now}
+		return interval, nil
+	}
+
+	// workloads are rolling out, or can't be restarted
+	return interval, nil
+}
+
+// restartTemplate annotates the pod template of the workload, unless it is already rolling out.
+// Returns whether the workload was restarted.
+func (r *ReconcilePodRule) restartTemplate(namespace, kind, name string, now time.Time) (bool, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	var object runtime.Object
+	var template *corev1.PodTemplateSpec
+
+	switch kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := r.Get(context.TODO(), key, deployment); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if deployment.Generation > deployment.Status.ObservedGeneration ||
+			deployment.Status.UpdatedReplicas < replicas ||
+			deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+			return false, nil
+		}
+		object, template = deployment, &deployment.Spec.Template
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		if err := r.Get(context.TODO(), key, statefulSet); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		if statefulSet.Generation > statefulSet.Status.ObservedGeneration ||
+			statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+			return false, nil
+		}
+		object, template = statefulSet, &statefulSet.Spec.Template
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		if err := r.Get(context.TODO(), key, daemonSet); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		if daemonSet.Generation > daemonSet.Status.ObservedGeneration ||
+			daemonSet.Status.UpdatedNumberScheduled < daemonSet.Status.DesiredNumberScheduled {
+			return false, nil
+		}
+		object, template = daemonSet, &daemonSet.Spec.Template
+	default:
+		// bare pods and other controllers can't be restarted
+		return false, nil
+	}
+
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[kuberule.AnnotationRestartedAt] = now.UTC().Format(time.RFC3339)
+	return true, r.Update(context.TODO(), object)
+}
+
+// ignoreNotFound returns nil on NotFound errors, workloads deleted meanwhile not being restarted
+func ignoreNotFound(err error) error {
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
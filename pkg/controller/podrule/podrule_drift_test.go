@@ -0,0 +1,124 @@
+package podrule
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+func TestReconcileDrift(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "drift", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Mutations: kuberule.PodMutations{
+				Labels: map[string]string{"team": "platform"},
+			},
+		},
+	}
+	driftKey := types.NamespacedName{Name: "drift", Namespace: "default"}
+	pod := func(name, appliedRules string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      map[string]string{"app": "web"},
+				Annotations: map[string]string{kuberule.AnnotationAppliedRules: appliedRules},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+		}
+	}
+	upToDate := pod("up-to-date", "drift="+instance.Spec.Mutations.Hash())
+	drifted := pod("drifted", "drift=00000000")
+
+	mgr, err := manager.New(cfg, manager.Options{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c = mgr.GetClient()
+
+	// several reconciles are expected, don't wrap the reconciler as nobody reads the requests
+	g.Expect(add(mgr, newReconciler(mgr))).NotTo(gomega.HaveOccurred())
+
+	stopMgr, mgrStopped := StartTestManager(mgr, g)
+
+	defer func() {
+		close(stopMgr)
+		mgrStopped.Wait()
+	}()
+
+	for _, p := range []*corev1.Pod{upToDate, drifted} {
+		err = c.Create(context.TODO(), p)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		defer c.Delete(context.TODO(), p)
+	}
+
+	err = c.Create(context.TODO(), instance)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	defer c.Delete(context.TODO(), instance)
+
+	status := func() kuberule.PodRuleStatus {
+		rule := &kuberule.PodRule{}
+		if err := c.Get(context.TODO(), driftKey, rule); err != nil {
+			return kuberule.PodRuleStatus{}
+		}
+		return rule.Status
+	}
+
+	// Only the pod created with former mutations is reported
+	g.Eventually(func() int32 { return status().DriftedPods }, timeout).Should(gomega.Equal(int32(1)))
+	g.Expect(status().DriftedWorkloads).To(gomega.Equal([]string{"Pod/drifted"}))
+
+	// Pods drift once the mutations of the rule change
+	rule := &kuberule.PodRule{}
+	g.Expect(c.Get(context.TODO(), driftKey, rule)).NotTo(gomega.HaveOccurred())
+	rule.Spec.Mutations.Labels["team"] = "web"
+	g.Expect(c.Update(context.TODO(), rule)).NotTo(gomega.HaveOccurred())
+	g.Eventually(func() int32 { return status().DriftedPods }, timeout).Should(gomega.Equal(int32(2)))
+}
+
+func TestPodDrifted(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rule := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: kuberule.PodRuleSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Mode:     kuberule.PodRuleModeEnforce,
+		},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pod := func(labels map[string]string, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations}}
+	}
+	web := map[string]string{"app": "web"}
+
+	// pods never mutated aren't checked
+	g.Expect(podDrifted(rule, "abc", pod(web, nil), namespace)).To(gomega.BeFalse())
+	// up to date
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: "foo=abc"}), namespace)).To(gomega.BeFalse())
+	// mutations changed
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: "foo=def"}), namespace)).To(gomega.BeTrue())
+	// rule created after the pod
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: ""}), namespace)).To(gomega.BeTrue())
+	// rule not selecting the pod anymore
+	g.Expect(podDrifted(rule, "abc", pod(nil, map[string]string{kuberule.AnnotationAppliedRules: "foo=abc"}), namespace)).To(gomega.BeTrue())
+	// rule excluded by the pod
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{
+		kuberule.AnnotationAppliedRules: "",
+		kuberule.AnnotationExcludeRules: "bar, foo",
+	}), namespace)).To(gomega.BeFalse())
+	// namespace not selected anymore
+	rule.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}}
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: "foo=abc"}), namespace)).To(gomega.BeTrue())
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: ""}), namespace)).To(gomega.BeFalse())
+	rule.Spec.NamespaceSelector = nil
+
+	// rules applied on part of the pods don't have to be applied on all of them
+	rule.Spec.Rollout = &kuberule.PodRuleRollout{}
+	g.Expect(podDrifted(rule, "abc", pod(web, map[string]string{kuberule.AnnotationAppliedRules: ""}), namespace)).To(gomega.BeFalse())
+}
@@ -0,0 +1,305 @@
+package podrule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxSummaryWorkloads limits the workloads listed in the deletion event
+const maxSummaryWorkloads = 10
+
+// finalize reports the workloads whose pods were mutated by the deleted rule,
+// stripping the labels and annotations it added when asked to
+func (r *ReconcilePodRule) finalize(instance *kuberule.PodRule) error {
+	if !hasFinalizer(instance) {
+		return nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(context.TODO(), client.InNamespace(instance.Namespace), podList); err != nil {
+		return err
+	}
+
+	stripMetadata := instance.Spec.OnDeletion != nil && instance.Spec.OnDeletion.StripMetadata
+	var stripper *metadataStripper
+	if stripMetadata {
+		var err error
+		if stripper, err = r.newMetadataStripper(instance); err != nil {
+			return err
+		}
+	}
+
+	affectedPods := 0
+	strippedPods := 0
+	deniedPods := 0
+	workloads := sets.NewString()
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if _, applied := kuberule.ParseAppliedRules(pod.Annotations[kuberule.AnnotationAppliedRules])[instance.Name]; !applied {
+			continue
+		}
+		affectedPods++
+
+		workload, err := podrules.PodWorkload(context.TODO(), r.Client, pod)
+		if err != nil {
+			return err
+		}
+		workloads.Insert(workload)
+
+		if !stripMetadata || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := stripper.strip(pod); err != nil {
+			return err
+		}
+		log.Info("stripping pod metadata", "podrule", instance.Namespace+"/"+instance.Name, "pod", pod.Name)
+		err = r.Update(context.TODO(), pod)
+		if updateDenied(err) {
+			// retrying would be denied again, the pod keeps its metadata
+			log.Error(err, "unable to strip pod metadata", "podrule", instance.Namespace+"/"+instance.Name, "pod", pod.Name)
+			deniedPods++
+			continue
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		strippedPods++
+	}
+
+	if affectedPods == 0 {
+		r.recorder.Event(instance, corev1.EventTypeNormal, "Finalized", "Rule was not applied on any running pod")
+		return nil
+	}
+
+	summary := workloads.List()
+	if len(summary) > maxSummaryWorkloads {
+		summary = append(summary[:maxSummaryWorkloads], fmt.Sprintf("and %d more", len(summary)-maxSummaryWorkloads))
+	}
+	message := fmt.Sprintf("Rule was applied on %d running pods of %s", affectedPods, strings.Join(summary, ", "))
+	if stripMetadata {
+		message += fmt.Sprintf(", metadata stripped from %d pods", strippedPods)
+		if deniedPods > 0 {
+			message += fmt.Sprintf(", updates of %d pods denied", deniedPods)
+		}
+	}
+	r.recorder.Event(instance, corev1.EventTypeNormal, "Finalized", message)
+
+	return nil
+}
+
+// updateDenied checks whether an update was refused, e.g. by RBAC or a validating webhook.
+// Webhooks denials are reported as bad requests with the reason given by the webhook.
+func updateDenied(err error) bool {
+	status, ok := err.(errors.APIStatus)
+	if !ok {
+		return false
+	}
+
+	switch status.Status().Code {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// hasFinalizer checks whether the rule still has the kuberule finalizer
+func hasFinalizer(instance *kuberule.PodRule) bool {
+	for _, finalizer := range instance.Finalizers {
+		if finalizer == kuberule.Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataStripper removes the labels and annotations added by a deleted rule from pods
+type metadataStripper struct {
+	client.Client
+	rule      *kuberule.PodRule
+	mutations *kuberule.PodMutations
+	namespace *corev1.Namespace
+	// other rules of the namespace, by name, their mutations being resolved
+	others map[string]*kuberule.PodRule
+}
+
+// newMetadataStripper resolves the mutations of the rule and of the other rules of its namespace.
+// Profiles deleted meanwhile are ignored, their metadata being kept.
+func (r *ReconcilePodRule) newMetadataStripper(instance *kuberule.PodRule) (*metadataStripper, error) {
+	mutations, err := podrules.ResolveExistingMutations(context.TODO(), r.Client, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	podRuleList := &kuberule.PodRuleList{}
+	if err := r.List(context.TODO(), client.InNamespace(instance.Namespace), podRuleList); err != nil {
+		return nil, err
+	}
+	others := map[string]*kuberule.PodRule{}
+	for i := range podRuleList.Items {
+		rule := &podRuleList.Items[i]
+		if rule.Name == instance.Name {
+			continue
+		}
+		resolved, err := podrules.ResolveExistingMutations(context.TODO(), r.Client, rule)
+		if err != nil {
+			return nil, err
+		}
+		rule.Spec.Mutations = *resolved
+		others[rule.Name] = rule
+	}
+
+	// mutations declared by the namespace annotations make an implicit rule selecting all pods
+	namespace := &corev1.Namespace{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: instance.Namespace}, namespace); err != nil {
+		return nil, err
+	}
+	if value := namespace.Annotations[kuberule.AnnotationMutations]; value != "" {
+		namespaceRule := &kuberule.PodRule{Spec: kuberule.PodRuleSpec{MatchAll: true}}
+		if err := json.Unmarshal([]byte(value), &namespaceRule.Spec.Mutations); err == nil {
+			others[kuberule.NamespaceRuleName] = namespaceRule
+		}
+	}
+
+	return &metadataStripper{Client: r.Client, rule: instance, mutations: mutations, namespace: namespace, others: others}, nil
+}
+
+// strip removes from the pod the labels and annotations of the rule still having the value it set,
+// then removes the rule from the applied rules record. Labels and annotations set by another rule
+// applied on the pod or selecting it, or required by the validations of another rule selecting it, are kept.
+// Labels selecting the pod for its workload are kept too, the workload would replace the pod otherwise.
+func (s *metadataStripper) strip(pod *corev1.Pod) error {
+	applied := kuberule.ParseAppliedRules(pod.Annotations[kuberule.AnnotationAppliedRules])
+	delete(applied, s.rule.Name)
+
+	keptLabels := sets.NewString()
+	keptAnnotations := sets.NewString()
+	for name, other := range s.others {
+		_, otherApplied := applied[name]
+		otherSelecting := s.selects(other, pod)
+		if otherApplied || otherSelecting {
+			keptLabels.Insert(keys(other.Spec.Mutations.Labels)...)
+			keptAnnotations.Insert(keys(other.Spec.Mutations.Annotations)...)
+		}
+		// pods can't opt out of validations, removing required metadata would be denied
+		if otherSelecting && other.Spec.Validations != nil {
+			keptLabels.Insert(other.Spec.Validations.RequiredLabels...)
+			keptAnnotations.Insert(other.Spec.Validations.RequiredAnnotations...)
+		}
+	}
+
+	selector, err := s.workloadSelector(pod)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range s.mutations.Labels {
+		if pod.Labels[key] != value || keptLabels.Has(key) {
+			continue
+		}
+		if selector != nil && !selector.Matches(labels.Set(withoutKey(pod.Labels, key))) {
+			continue
+		}
+		delete(pod.Labels, key)
+	}
+	for key, value := range s.mutations.Annotations {
+		if pod.Annotations[key] == value && !keptAnnotations.Has(key) {
+			delete(pod.Annotations, key)
+		}
+	}
+
+	if len(applied) == 0 {
+		delete(pod.Annotations, kuberule.AnnotationAppliedRules)
+	} else {
+		pod.Annotations[kuberule.AnnotationAppliedRules] = kuberule.FormatAppliedRules(applied)
+	}
+
+	return nil
+}
+
+// selects checks whether the enabled rule selects the pod and its namespace, whatever the pod annotations
+func (s *metadataStripper) selects(rule *kuberule.PodRule, pod *corev1.Pod) bool {
+	if rule.Spec.Mode == kuberule.PodRuleModeDisabled {
+		return false
+	}
+
+	// rules with invalid selectors are skipped by the webhooks
+	selector, err := metav1.LabelSelectorAsSelector(&rule.Spec.Selector)
+	if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+		return false
+	}
+	namespaceSelected, err := rule.Spec.SelectsNamespace(s.namespace)
+	return err == nil && namespaceSelected
+}
+
+// workloadSelector returns the selector of the controller owning the pod, nil for pods without controller.
+// Pods of controllers not known or not found are never unlabeled, Nothing being returned.
+func (s *metadataStripper) workloadSelector(pod *corev1.Pod) (labels.Selector, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}
+	var object runtime.Object
+	var selector **metav1.LabelSelector
+	switch owner.Kind {
+	case "ReplicaSet":
+		replicaSet := &appsv1.ReplicaSet{}
+		object, selector = replicaSet, &replicaSet.Spec.Selector
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		object, selector = statefulSet, &statefulSet.Spec.Selector
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		object, selector = daemonSet, &daemonSet.Spec.Selector
+	default:
+		return labels.Nothing(), nil
+	}
+
+	err := s.Get(context.TODO(), key, object)
+	if errors.IsNotFound(err) {
+		return labels.Nothing(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if *selector == nil {
+		return labels.Nothing(), nil
+	}
+	return metav1.LabelSelectorAsSelector(*selector)
+}
+
+// keys returns the keys of the map
+func keys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
+
+// withoutKey returns a copy of the labels without the given key
+func withoutKey(m map[string]string, key string) map[string]string {
+	result := map[string]string{}
+	for k, v := range m {
+		if k != key {
+			result[k] = v
+		}
+	}
+	return result
+}
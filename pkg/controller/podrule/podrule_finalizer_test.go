@@ -0,0 +1,124 @@
+package podrule
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	"golang.org/x/net/context"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replicaSetsClient serves the given ReplicaSets, other ones not being found
+type replicaSetsClient struct {
+	client.Client
+	replicaSets map[string]*appsv1.ReplicaSet
+}
+
+func (c *replicaSetsClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	replicaSet, ok := c.replicaSets[key.Name]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "replicasets"}, key.Name)
+	}
+	replicaSet.DeepCopyInto(obj.(*appsv1.ReplicaSet))
+	return nil
+}
+
+func TestStripMetadata(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	selecting := func(mode kuberule.PodRuleMode, mutations kuberule.PodMutations, validations *kuberule.PodValidations) *kuberule.PodRule {
+		return &kuberule.PodRule{Spec: kuberule.PodRuleSpec{
+			Selector:    metav1.LabelSelector{MatchLabels: map[string]string{"app": "qux"}},
+			Mode:        mode,
+			Mutations:   mutations,
+			Validations: validations,
+		}}
+	}
+	stripper := &metadataStripper{
+		Client: &replicaSetsClient{replicaSets: map[string]*appsv1.ReplicaSet{
+			"qux-1": {Spec: appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "qux"}}}},
+		}},
+		rule: &kuberule.PodRule{ObjectMeta: metav1.ObjectMeta{Name: "qux"}},
+		mutations: &kuberule.PodMutations{
+			Labels:      map[string]string{"team": "platform", "tier": "app"},
+			Annotations: map[string]string{"example.com/owner": "platform", "example.com/tier": "app"},
+		},
+		namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		others: map[string]*kuberule.PodRule{
+			// selecting the pod without being recorded on it, e.g. created later
+			"owners": selecting(kuberule.PodRuleModeEnforce, kuberule.PodMutations{
+				Annotations: map[string]string{"example.com/owner": "platform"},
+			}, nil),
+			// validations would deny removing the label
+			"require-team": selecting(kuberule.PodRuleModeEnforce, kuberule.PodMutations{}, &kuberule.PodValidations{
+				RequiredLabels: []string{"team"},
+			}),
+			"disabled": selecting(kuberule.PodRuleModeDisabled, kuberule.PodMutations{}, &kuberule.PodValidations{
+				RequiredLabels:      []string{"tier"},
+				RequiredAnnotations: []string{"example.com/tier"},
+			}),
+		},
+	}
+	pod := func(owner string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "qux",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "qux", "team": "platform", "tier": "app"},
+			Annotations: map[string]string{
+				"example.com/owner":             "platform",
+				"example.com/tier":              "app",
+				kuberule.AnnotationAppliedRules: "qux=00000000",
+			},
+		}}
+		if owner != "" {
+			controller := true
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+		}
+		return pod
+	}
+
+	stripped := pod("")
+	g.Expect(stripper.strip(stripped)).NotTo(gomega.HaveOccurred())
+	g.Expect(stripped.Labels).To(gomega.Equal(map[string]string{"app": "qux", "team": "platform"}))
+	g.Expect(stripped.Annotations).To(gomega.Equal(map[string]string{"example.com/owner": "platform"}))
+
+	// labels selecting the pod for its ReplicaSet are kept
+	stripped = pod("qux-1")
+	stripper.mutations.Labels["app"] = "qux"
+	g.Expect(stripper.strip(stripped)).NotTo(gomega.HaveOccurred())
+	g.Expect(stripped.Labels).To(gomega.Equal(map[string]string{"app": "qux", "team": "platform"}))
+	delete(stripper.mutations.Labels, "app")
+
+	// pods of a ReplicaSet not found are never unlabeled
+	stripped = pod("qux-2")
+	g.Expect(stripper.strip(stripped)).NotTo(gomega.HaveOccurred())
+	g.Expect(stripped.Labels).To(gomega.Equal(map[string]string{"app": "qux", "team": "platform", "tier": "app"}))
+	g.Expect(stripped.Annotations).To(gomega.Equal(map[string]string{"example.com/owner": "platform"}))
+}
+
+func TestUpdateDenied(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pods := schema.GroupResource{Resource: "pods"}
+
+	g.Expect(updateDenied(nil)).To(gomega.BeFalse())
+	g.Expect(updateDenied(fmt.Errorf("connection refused"))).To(gomega.BeFalse())
+	g.Expect(updateDenied(errors.NewNotFound(pods, "qux"))).To(gomega.BeFalse())
+	g.Expect(updateDenied(errors.NewConflict(pods, "qux", fmt.Errorf("modified")))).To(gomega.BeFalse())
+
+	g.Expect(updateDenied(errors.NewForbidden(pods, "qux", fmt.Errorf("not allowed")))).To(gomega.BeTrue())
+	// as returned for validating webhooks denials
+	g.Expect(updateDenied(&errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusBadRequest,
+		Reason:  "pod violates rule require-team: missing label team",
+		Message: `admission webhook "validatepods.kuberule.chickenzord.com" denied the request: pod violates rule require-team: missing label team`,
+	}})).To(gomega.BeTrue())
+}
@@ -0,0 +1,113 @@
+// Package podrules contains the lookups of PodRules and of the objects they depend on,
+// shared by the webhooks and the controllers.
+package podrules
+
+import (
+	"context"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("podrules")
+
+// GetPodMutationProfile returns the mutations of the referenced profile,
+// namespaced profiles being looked up in the given namespace
+func GetPodMutationProfile(ctx context.Context, c client.Client, namespace string, ref kuberule.PodMutationProfileReference) (*kuberule.PodMutations, error) {
+	if ref.Kind == kuberule.PodMutationProfileKindCluster {
+		profile := &kuberule.ClusterPodMutationProfile{}
+		if err := c.Get(ctx, types.NamespacedName{Name: ref.Name}, profile); err != nil {
+			return nil, err
+		}
+		return &profile.Spec.Mutations, nil
+	}
+
+	profile := &kuberule.PodMutationProfile{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, profile); err != nil {
+		return nil, err
+	}
+	return &profile.Spec.Mutations, nil
+}
+
+// ResolveMutations merges the mutations of the profiles referenced by the rule, in order,
+// then the inline mutations of the rule
+func ResolveMutations(ctx context.Context, c client.Client, rule *kuberule.PodRule) (*kuberule.PodMutations, error) {
+	return rule.Spec.ResolveMutations(func(ref kuberule.PodMutationProfileReference) (*kuberule.PodMutations, error) {
+		return GetPodMutationProfile(ctx, c, rule.Namespace, ref)
+	})
+}
+
+// ResolveExistingMutations is like ResolveMutations, ignoring the referenced profiles not found
+func ResolveExistingMutations(ctx context.Context, c client.Client, rule *kuberule.PodRule) (*kuberule.PodMutations, error) {
+	return rule.Spec.ResolveMutations(func(ref kuberule.PodMutationProfileReference) (*kuberule.PodMutations, error) {
+		mutations, err := GetPodMutationProfile(ctx, c, rule.Namespace, ref)
+		if errors.IsNotFound(err) {
+			return &kuberule.PodMutations{}, nil
+		}
+		return mutations, err
+	})
+}
+
+// ExcludedRules returns names of rules excluded by either the pod or its namespace
+func ExcludedRules(pod *corev1.Pod, namespace *corev1.Namespace) sets.String {
+	excluded := sets.NewString()
+	for _, annotations := range []map[string]string{pod.Annotations, namespace.Annotations} {
+		for _, name := range strings.Split(annotations[kuberule.AnnotationExcludeRules], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				excluded.Insert(name)
+			}
+		}
+	}
+
+	return excluded
+}
+
+// PodWorkload returns the workload running the pod as Kind/name, e.g. Deployment/web
+func PodWorkload(ctx context.Context, c client.Client, pod *corev1.Pod) (string, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod/" + pod.Name, nil
+	}
+	if owner.Kind != "ReplicaSet" {
+		return owner.Kind + "/" + owner.Name, nil
+	}
+
+	replicaSet := &appsv1.ReplicaSet{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, replicaSet)
+	if errors.IsNotFound(err) {
+		return owner.Kind + "/" + owner.Name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if deployment := metav1.GetControllerOf(replicaSet); deployment != nil {
+		return deployment.Kind + "/" + deployment.Name, nil
+	}
+	return owner.Kind + "/" + owner.Name, nil
+}
+
+// NamespaceRequests returns reconcile requests for all PodRules of the namespace, or of all namespaces if empty
+func NamespaceRequests(c client.Client, namespace string) []reconcile.Request {
+	podRuleList := &kuberule.PodRuleList{}
+	if err := c.List(context.TODO(), client.InNamespace(namespace), podRuleList); err != nil {
+		log.Error(err, "unable to list podrules", "namespace", namespace)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, rule := range podRuleList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name},
+		})
+	}
+	return requests
+}
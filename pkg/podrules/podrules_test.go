@@ -0,0 +1,74 @@
+package podrules
+
+import (
+	"context"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replicaSetsClient serves the given ReplicaSets, other ones not being found
+type replicaSetsClient struct {
+	client.Client
+	replicaSets map[string]*appsv1.ReplicaSet
+}
+
+func (c *replicaSetsClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	replicaSet, ok := c.replicaSets[key.Name]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "replicasets"}, key.Name)
+	}
+	replicaSet.DeepCopyInto(obj.(*appsv1.ReplicaSet))
+	return nil
+}
+
+func TestExcludedRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	pod := &corev1.Pod{}
+	pod.Annotations = map[string]string{kuberule.AnnotationExcludeRules: "rule-a, rule-b,"}
+	namespace := &corev1.Namespace{}
+	namespace.Annotations = map[string]string{kuberule.AnnotationExcludeRules: "rule-c"}
+
+	g.Expect(ExcludedRules(pod, namespace).List()).To(gomega.Equal([]string{"rule-a", "rule-b", "rule-c"}))
+	g.Expect(ExcludedRules(&corev1.Pod{}, &corev1.Namespace{}).Len()).To(gomega.Equal(0))
+}
+
+func TestPodWorkload(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	controller := true
+	ownedBy := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	}
+	c := &replicaSetsClient{replicaSets: map[string]*appsv1.ReplicaSet{
+		"web-1":  {ObjectMeta: metav1.ObjectMeta{OwnerReferences: ownedBy("Deployment", "web")}},
+		"bare-1": {},
+	}}
+	pod := func(owners []metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", OwnerReferences: owners}}
+	}
+
+	for _, test := range []struct {
+		owners   []metav1.OwnerReference
+		workload string
+	}{
+		{nil, "Pod/pod"},
+		{ownedBy("StatefulSet", "db"), "StatefulSet/db"},
+		{ownedBy("ReplicaSet", "web-1"), "Deployment/web"},
+		{ownedBy("ReplicaSet", "bare-1"), "ReplicaSet/bare-1"},
+		// deleted meanwhile
+		{ownedBy("ReplicaSet", "gone-1"), "ReplicaSet/gone-1"},
+	} {
+		workload, err := PodWorkload(context.TODO(), c, pod(test.owners))
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(workload).To(gomega.Equal(test.workload))
+	}
+}
@@ -0,0 +1,411 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Keys of the certificates secret, compatible with the secrets written by cert-manager.
+// The key of a renewed CA not issuing serving certificates yet is kept under secretNextCAKeyKey.
+const (
+	secretServingCertKey = "tls.crt"
+	secretServingKeyKey  = "tls.key"
+	secretCACertKey      = "ca.crt"
+	secretCAKeyKey       = "ca.key"
+	secretNextCAKeyKey   = "next-ca.key"
+)
+
+// certRotator keeps the serving certificate of the webhook server in a secret, issuing it from a
+// self-managed CA and renewing both before they expire. With issue disabled, certificates are
+// issued by cert-manager into the secret and only loaded.
+// Certificates are reloaded from the secret periodically, so all replicas serve the latest ones.
+type certRotator struct {
+	client   client.Client
+	secret   types.NamespacedName
+	interval time.Duration
+	now      func() time.Time
+
+	// caInstalled checks whether the webhook configurations trust the CA,
+	// a renewed CA only issuing serving certificates once they do
+	caInstalled func(ctx context.Context, ca *x509.Certificate) (bool, error)
+
+	issue       bool
+	dnsNames    []string
+	validity    time.Duration
+	caValidity  time.Duration
+	renewBefore time.Duration
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	caBundle    []byte
+}
+
+var _ manager.Runnable = &certRotator{}
+
+// Start implements manager.Runnable
+func (r *certRotator) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		err := r.reconcile(context.TODO())
+		// replicas race to update the secret, the winner's certificates are loaded on retry
+		if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+			err = r.reconcile(context.TODO())
+		}
+		if err != nil {
+			log.Error(err, "unable to rotate certificates", "secret", r.secret)
+		}
+	}, r.interval, stop)
+
+	return nil
+}
+
+// GetCertificate returns the current serving certificate, for tls.Config
+func (r *certRotator) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.certificate == nil {
+		return nil, fmt.Errorf("serving certificate not loaded yet from secret %s", r.secret)
+	}
+	return r.certificate, nil
+}
+
+// CABundle returns the PEM encoded CAs trusted to sign the serving certificate, empty until loaded
+func (r *certRotator) CABundle() []byte {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.caBundle
+}
+
+// reconcile issues or renews the certificates in the secret if needed, then loads them
+func (r *certRotator) reconcile(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	err := r.client.Get(ctx, r.secret, secret)
+	if errors.IsNotFound(err) {
+		if !r.issue {
+			log.Info("waiting for cert-manager to issue certificates", "secret", r.secret)
+			return nil
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.secret.Namespace, Name: r.secret.Name},
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if r.issue {
+		now := r.now()
+		nextCAInstalled := false
+		if _, next := signingCA(secret.Data, secretNextCAKeyKey, now); next != nil {
+			if nextCAInstalled, err = r.caInstalled(ctx, next); err != nil {
+				return err
+			}
+		}
+
+		data, changed, err := r.rotate(secret.Data, now, nextCAInstalled)
+		if err != nil {
+			return err
+		}
+		if changed {
+			secret.Data = data
+			if secret.ResourceVersion == "" {
+				log.Info("creating certificates", "secret", r.secret)
+				err = r.client.Create(ctx, secret)
+			} else {
+				log.Info("renewing certificates", "secret", r.secret)
+				err = r.client.Update(ctx, secret)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.load(secret.Data)
+}
+
+// load switches to the certificates of the secret data, if changed
+func (r *certRotator) load(data map[string][]byte) error {
+	if len(data[secretServingCertKey]) == 0 || len(data[secretCACertKey]) == 0 {
+		log.Info("waiting for certificates", "secret", r.secret)
+		return nil
+	}
+
+	certificate, err := tls.X509KeyPair(data[secretServingCertKey], data[secretServingKeyKey])
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.certificate != nil && bytes.Equal(r.certificate.Certificate[0], certificate.Certificate[0]) &&
+		bytes.Equal(r.caBundle, data[secretCACertKey]) {
+		return nil
+	}
+
+	log.Info("loading certificates", "secret", r.secret)
+	r.certificate = &certificate
+	r.caBundle = data[secretCACertKey]
+	return nil
+}
+
+// rotate returns the secret data with the CA and serving certificate issued or renewed as needed,
+// and whether it changed. A renewed CA is first only added to the trusted CAs, serving certificates
+// being issued by the former CA until the renewed one is installed in the webhook configurations,
+// so API servers keep accepting the serving certificates of all replicas during the rollover.
+// The former CA is trusted until it expires.
+func (r *certRotator) rotate(data map[string][]byte, now time.Time, nextCAInstalled bool) (map[string][]byte, bool, error) {
+	result := map[string][]byte{}
+	for key, value := range data {
+		result[key] = value
+	}
+	changed := false
+
+	bundle := parseCertificates(data[secretCACertKey])
+	caKey, ca := signingCA(data, secretCAKeyKey, now)
+	nextCAKey, nextCA := signingCA(data, secretNextCAKeyKey, now)
+	if nextCA == nil && len(data[secretNextCAKeyKey]) > 0 {
+		delete(result, secretNextCAKeyKey)
+		changed = true
+	}
+
+	// the renewed CA issues serving certificates once trusted, or once the former one expired
+	if nextCA != nil && (nextCAInstalled || ca == nil) {
+		ca, caKey = nextCA, nextCAKey
+		nextCA = nil
+		result[secretCAKeyKey] = data[secretNextCAKeyKey]
+		delete(result, secretNextCAKeyKey)
+		changed = true
+	}
+
+	switch {
+	case ca == nil:
+		// nothing to roll over, the CA is used right away
+		var err error
+		if ca, caKey, err = newCA(now, r.caValidity); err != nil {
+			return nil, false, err
+		}
+		bundle = append([]*x509.Certificate{ca}, bundle...)
+		result[secretCAKeyKey] = encodePrivateKey(caKey)
+		changed = true
+	case nextCA == nil && ca.NotAfter.Sub(now) < r.renewBefore:
+		next, nextKey, err := newCA(now, r.caValidity)
+		if err != nil {
+			return nil, false, err
+		}
+		bundle = append([]*x509.Certificate{next}, bundle...)
+		result[secretNextCAKeyKey] = encodePrivateKey(nextKey)
+		changed = true
+	}
+
+	// drop expired CAs from the bundle
+	trusted := []*x509.Certificate{}
+	for _, certificate := range bundle {
+		if certificate.NotAfter.After(now) {
+			trusted = append(trusted, certificate)
+		}
+	}
+	caBundle := encodeCertificates(trusted)
+	if !bytes.Equal(caBundle, data[secretCACertKey]) {
+		result[secretCACertKey] = caBundle
+		changed = true
+	}
+
+	if !r.servingCertValid(data, ca, now) {
+		certificate, key, err := newServingCert(now, r.validity, r.dnsNames, ca, caKey)
+		if err != nil {
+			return nil, false, err
+		}
+		result[secretServingCertKey] = certificate
+		result[secretServingKeyKey] = encodePrivateKey(key)
+		changed = true
+	}
+
+	return result, changed, nil
+}
+
+// signingCA returns the key stored at keyKey in the secret data and its CA certificate from the
+// trusted CAs, nil if either is missing or the CA expired
+func signingCA(data map[string][]byte, keyKey string, now time.Time) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := parsePrivateKey(data[keyKey])
+	if err != nil {
+		return nil, nil
+	}
+	for _, certificate := range parseCertificates(data[secretCACertKey]) {
+		if publicKeyMatches(certificate, key) && certificate.NotAfter.After(now) {
+			return key, certificate
+		}
+	}
+	return nil, nil
+}
+
+// servingCertValid checks the serving certificate of the secret data is issued by the CA for the
+// service DNS names, and doesn't have to be renewed yet. Certificates lasting as long as their CA
+// are kept, a renewed one couldn't last longer.
+func (r *certRotator) servingCertValid(data map[string][]byte, ca *x509.Certificate, now time.Time) bool {
+	if _, err := tls.X509KeyPair(data[secretServingCertKey], data[secretServingKeyKey]); err != nil {
+		return false
+	}
+	certificates := parseCertificates(data[secretServingCertKey])
+	if len(certificates) == 0 {
+		return false
+	}
+	certificate := certificates[0]
+	if certificate.NotAfter.Sub(now) < r.renewBefore && certificate.NotAfter.Before(ca.NotAfter) {
+		return false
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, name := range r.dnsNames {
+		_, err := certificate.Verify(x509.VerifyOptions{
+			DNSName:     name,
+			Roots:       roots,
+			CurrentTime: now,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceDNSNames returns the names the API servers may use to reach the service
+func serviceDNSNames(service types.NamespacedName) []string {
+	return []string{
+		service.Name,
+		service.Name + "." + service.Namespace,
+		service.Name + "." + service.Namespace + ".svc",
+		service.Name + "." + service.Namespace + ".svc.cluster.local",
+	}
+}
+
+// newCA returns a self-signed CA certificate and its key
+func newCA(now time.Time, validity time.Duration) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("kuberule-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certificate, key, nil
+}
+
+// newServingCert returns a PEM encoded serving certificate for the DNS names signed by the CA, and its key.
+// The certificate never outlives the CA.
+func newServingCert(now time.Time, validity time.Duration, dnsNames []string, ca *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[len(dnsNames)-1]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), key, nil
+}
+
+// parseCertificates parses the PEM encoded certificates, skipping invalid ones
+func parseCertificates(data []byte) []*x509.Certificate {
+	certificates := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certificates
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if certificate, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certificates = append(certificates, certificate)
+		}
+	}
+}
+
+// encodeCertificates returns the PEM encoding of the certificates
+func encodeCertificates(certificates []*x509.Certificate) []byte {
+	buffer := &bytes.Buffer{}
+	for _, certificate := range certificates {
+		pem.Encode(buffer, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	}
+	return buffer.Bytes()
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded private key found")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// encodePrivateKey returns the PEM encoding of the RSA private key
+func encodePrivateKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// publicKeyMatches checks whether the certificate is the one of the key
+func publicKeyMatches(certificate *x509.Certificate, key *rsa.PrivateKey) bool {
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	return ok && publicKey.N.Cmp(key.N) == 0 && publicKey.E == key.E
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRotateCertificates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rotator := &certRotator{
+		issue:       true,
+		dnsNames:    serviceDNSNames(types.NamespacedName{Namespace: "kuberule", Name: "kuberule"}),
+		validity:    90 * 24 * time.Hour,
+		caValidity:  365 * 24 * time.Hour,
+		renewBefore: 30 * 24 * time.Hour,
+	}
+	now := time.Now()
+	day := func(days int) time.Time {
+		return now.Add(time.Duration(days) * 24 * time.Hour)
+	}
+
+	// certificates are issued into empty secrets
+	data, changed, err := rotator.rotate(nil, now, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(rotator.servingCertValid(data, parseCertificates(data[secretCACertKey])[0], now)).To(gomega.BeTrue())
+	_, err = tls.X509KeyPair(data[secretServingCertKey], data[secretServingKeyKey])
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// valid certificates are kept
+	kept, changed, err := rotator.rotate(data, now.Add(time.Hour), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeFalse())
+	g.Expect(kept).To(gomega.Equal(data))
+
+	// the serving certificate is renewed before it expires, by the same CA
+	renewed, changed, err := rotator.rotate(data, day(61), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(renewed[secretServingCertKey]).NotTo(gomega.Equal(data[secretServingCertKey]))
+	g.Expect(renewed[secretCACertKey]).To(gomega.Equal(data[secretCACertKey]))
+
+	// the CA is renewed before it expires, the renewed one being only trusted at first
+	rolling, changed, err := rotator.rotate(renewed, day(340), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	bundle := parseCertificates(rolling[secretCACertKey])
+	g.Expect(bundle).To(gomega.HaveLen(2))
+	g.Expect(bundle[1].Raw).To(gomega.Equal(parseCertificates(data[secretCACertKey])[0].Raw))
+	g.Expect(rolling[secretCAKeyKey]).To(gomega.Equal(data[secretCAKeyKey]))
+	g.Expect(rolling[secretNextCAKeyKey]).NotTo(gomega.BeEmpty())
+	g.Expect(rotator.servingCertValid(rolling, bundle[1], day(340))).To(gomega.BeTrue())
+	g.Expect(rotator.servingCertValid(rolling, bundle[0], day(340))).To(gomega.BeFalse())
+
+	// serving certificates keep being issued by the former CA until the renewed one is installed
+	waiting, changed, err := rotator.rotate(rolling, day(341), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeFalse())
+	g.Expect(waiting).To(gomega.Equal(rolling))
+
+	// the renewed CA issues the serving certificate once installed, the former one staying trusted
+	rolled, changed, err := rotator.rotate(rolling, day(341), true)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(rolled[secretCACertKey]).To(gomega.Equal(rolling[secretCACertKey]))
+	g.Expect(rolled[secretCAKeyKey]).To(gomega.Equal(rolling[secretNextCAKeyKey]))
+	g.Expect(rolled).NotTo(gomega.HaveKey(secretNextCAKeyKey))
+	g.Expect(rotator.servingCertValid(rolled, bundle[0], day(341))).To(gomega.BeTrue())
+
+	// or once the former CA expired
+	expired, changed, err := rotator.rotate(rolling, day(366), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(expired[secretCAKeyKey]).To(gomega.Equal(rolling[secretNextCAKeyKey]))
+	g.Expect(rotator.servingCertValid(expired, bundle[0], day(366))).To(gomega.BeTrue())
+
+	// expired CAs are dropped from the bundle
+	pruned, changed, err := rotator.rotate(rolled, day(366), false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(parseCertificates(pruned[secretCACertKey])).To(gomega.HaveLen(1))
+}
@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	kuberulev1alpha1 "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1alpha1"
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	webhooktypes "sigs.k8s.io/controller-runtime/pkg/webhook/types"
+)
+
+const (
+	convertWebhookName = "convert.kuberule.chickenzord.com"
+	convertWebhookPath = "/convert"
+)
+
+// conversionWebhookType is neither mutating nor validating,
+// so the server doesn't install it in webhook configurations
+const conversionWebhookType webhooktypes.WebhookType = 0
+
+// conversionWebhook serves CRD conversion reviews between v1alpha1 and the v1beta1 storage version
+type conversionWebhook struct{}
+
+// conversionWebhook Implements webhook.Webhook.
+var _ webhook.Webhook = &conversionWebhook{}
+
+// GetName implements webhook.Webhook
+func (w *conversionWebhook) GetName() string {
+	return convertWebhookName
+}
+
+// GetPath implements webhook.Webhook
+func (w *conversionWebhook) GetPath() string {
+	return convertWebhookPath
+}
+
+// GetType implements webhook.Webhook
+func (w *conversionWebhook) GetType() webhooktypes.WebhookType {
+	return conversionWebhookType
+}
+
+// Handler implements webhook.Webhook
+func (w *conversionWebhook) Handler() http.Handler {
+	return w
+}
+
+// Validate implements webhook.Webhook
+func (w *conversionWebhook) Validate() error {
+	return nil
+}
+
+// ServeHTTP handles a conversion review
+func (w *conversionWebhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	review := &apiextensionsv1beta1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(rw, "invalid conversion review", http.StatusBadRequest)
+		return
+	}
+
+	log.Info("converting objects",
+		"count", len(review.Request.Objects),
+		"desiredAPIVersion", review.Request.DesiredAPIVersion,
+	)
+
+	review.Response = convertReview(review.Request)
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		log.Error(err, "unable to write conversion response")
+	}
+}
+
+// convertReview converts all objects of the request, failing on the first error
+func convertReview(request *apiextensionsv1beta1.ConversionRequest) *apiextensionsv1beta1.ConversionResponse {
+	response := &apiextensionsv1beta1.ConversionResponse{UID: request.UID}
+
+	desired, err := schema.ParseGroupVersion(request.DesiredAPIVersion)
+	if err != nil {
+		response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+		return response
+	}
+
+	for _, object := range request.Objects {
+		converted, err := convertObject(object.Raw, desired)
+		if err != nil {
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+// convertObject converts a single JSON object to the desired version
+func convertObject(raw []byte, desired schema.GroupVersion) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	current, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	if current == desired {
+		return raw, nil
+	}
+
+	var toV1beta1 bool
+	switch {
+	case current == kuberulev1alpha1.SchemeGroupVersion && desired == kuberule.SchemeGroupVersion:
+		toV1beta1 = true
+	case current == kuberule.SchemeGroupVersion && desired == kuberulev1alpha1.SchemeGroupVersion:
+		toV1beta1 = false
+	default:
+		return nil, fmt.Errorf("unsupported conversion of %s from %s to %s", typeMeta.Kind, current, desired)
+	}
+
+	var converted runtime.Object
+	switch typeMeta.Kind {
+	case "PodRule":
+		converted, err = convertPodRule(raw, toV1beta1)
+	case "PodRulePolicy":
+		converted, err = convertPodRulePolicy(raw, toV1beta1)
+	default:
+		return nil, fmt.Errorf("unsupported conversion of kind %s", typeMeta.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	converted.GetObjectKind().SetGroupVersionKind(desired.WithKind(typeMeta.Kind))
+	return json.Marshal(converted)
+}
+
+func convertPodRule(raw []byte, toV1beta1 bool) (runtime.Object, error) {
+	if toV1beta1 {
+		src := &kuberulev1alpha1.PodRule{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &kuberule.PodRule{}
+		src.ConvertTo(dst)
+		return dst, nil
+	}
+
+	src := &kuberule.PodRule{}
+	if err := json.Unmarshal(raw, src); err != nil {
+		return nil, err
+	}
+	dst := &kuberulev1alpha1.PodRule{}
+	dst.ConvertFrom(src)
+	return dst, nil
+}
+
+func convertPodRulePolicy(raw []byte, toV1beta1 bool) (runtime.Object, error) {
+	if toV1beta1 {
+		src := &kuberulev1alpha1.PodRulePolicy{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &kuberule.PodRulePolicy{}
+		src.ConvertTo(dst)
+		return dst, nil
+	}
+
+	src := &kuberule.PodRulePolicy{}
+	if err := json.Unmarshal(raw, src); err != nil {
+		return nil, err
+	}
+	dst := &kuberulev1alpha1.PodRulePolicy{}
+	dst.ConvertFrom(src)
+	return dst, nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConvertReview(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	podRule := []byte(`{
+		"apiVersion": "kuberule.chickenzord.com/v1alpha1",
+		"kind": "PodRule",
+		"metadata": {"name": "foo", "namespace": "default"},
+		"spec": {
+			"selector": {"matchLabels": {"tier": "app"}},
+			"mode": "audit",
+			"mutations": {"nodeSelector": {"kubernetes.io/role": "app"}},
+			"strategies": {"nodeSelector": "Override"}
+		}
+	}`)
+
+	response := convertReview(&apiextensionsv1beta1.ConversionRequest{
+		UID:               "1",
+		DesiredAPIVersion: kuberule.SchemeGroupVersion.String(),
+		Objects:           []runtime.RawExtension{{Raw: podRule}},
+	})
+	g.Expect(response.UID).To(gomega.BeEquivalentTo("1"))
+	g.Expect(response.Result.Status).To(gomega.Equal(metav1.StatusSuccess))
+	g.Expect(response.ConvertedObjects).To(gomega.HaveLen(1))
+
+	converted := &kuberule.PodRule{}
+	g.Expect(json.Unmarshal(response.ConvertedObjects[0].Raw, converted)).NotTo(gomega.HaveOccurred())
+	g.Expect(converted.APIVersion).To(gomega.Equal(kuberule.SchemeGroupVersion.String()))
+	g.Expect(converted.Name).To(gomega.Equal("foo"))
+	g.Expect(converted.Spec.Mode).To(gomega.Equal(kuberule.PodRuleModeAudit))
+	g.Expect(converted.Spec.Mutations.Strategies.NodeSelector).To(gomega.Equal(kuberule.MutationStrategyOverride))
+
+	// unknown kinds fail the whole review
+	response = convertReview(&apiextensionsv1beta1.ConversionRequest{
+		DesiredAPIVersion: kuberule.SchemeGroupVersion.String(),
+		Objects: []runtime.RawExtension{
+			{Raw: podRule},
+			{Raw: []byte(`{"apiVersion": "kuberule.chickenzord.com/v1alpha1", "kind": "Unknown"}`)},
+		},
+	})
+	g.Expect(response.Result.Status).To(gomega.Equal(metav1.StatusFailure))
+	g.Expect(response.ConvertedObjects).To(gomega.BeEmpty())
+}
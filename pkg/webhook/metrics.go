@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// evaluationResultApplied means the rule mutated the pod
+	evaluationResultApplied = "applied"
+
+	// evaluationResultAudited means the rule would have mutated or denied the pod, see audit mode
+	evaluationResultAudited = "audited"
+
+	// evaluationResultSkipped means the pod is not included in the rule rollout
+	evaluationResultSkipped = "skipped"
+
+	// evaluationResultInactive means the rule is outside of its active time window
+	evaluationResultInactive = "inactive"
+
+	// evaluationResultDenied means the pod violated the rule validations
+	evaluationResultDenied = "denied"
+
+	// evaluationResultUnresolved means a mutation profile referenced by the rule doesn't exist
+	evaluationResultUnresolved = "unresolved"
+
+	// evaluationResultForbidden means the resolved mutations of the rule are not allowed by the PodRulePolicies
+	evaluationResultForbidden = "forbidden"
+)
+
+var (
+	// podRuleEvaluations counts rules matching admitted pods, by result
+	podRuleEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kuberule_podrule_evaluations_total",
+		Help: "Total number of pods matched by pod rules, partitioned by namespace, rule and result",
+	}, []string{"namespace", "rule", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(podRuleEvaluations)
+}
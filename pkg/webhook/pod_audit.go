@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// maxLastAudits is the number of audits kept in rule status
+const maxLastAudits = 10
+
+// podDisplayName returns the pod name, falling back to generateName for pods not yet named
+func podDisplayName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
+
+// auditPod records the patch an audit mode rule would have applied on the given pod
+// in metrics, events and the rule status.
+func (a *podMutationHandler) auditPod(rule kuberule.PodRule, pod *corev1.Pod, operation string, patch interface{}) {
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		log.Error(err, "unable to marshal audit patch", "rule", rule.Name)
+		return
+	}
+
+	audit := kuberule.PodRuleAudit{
+		Pod:       podDisplayName(pod),
+		Operation: operation,
+		Patch:     string(patchJSON),
+		Time:      metav1.Now(),
+	}
+
+	log.Info("auditing pod",
+		"rule", rule.Name,
+		"pod", audit.Pod,
+		"patch", audit.Patch,
+	)
+	podRuleEvaluations.WithLabelValues(rule.Namespace, rule.Name, evaluationResultAudited).Inc()
+	a.recorder.Eventf(&rule, corev1.EventTypeNormal, "Audited",
+		"%s pod %s would be mutated: %s", operation, audit.Pod, audit.Patch)
+
+	// status is recorded in the background to not slow down admission
+	a.audits.Add(rule, audit)
+}
+
+// auditQueueSize bounds the audits waiting to be recorded in rule status
+const auditQueueSize = 1000
+
+// auditRecord is an audit waiting to be recorded in the status of its rule
+type auditRecord struct {
+	rule  types.NamespacedName
+	audit kuberule.PodRuleAudit
+}
+
+// auditQueue records audits in rule status from a single worker.
+// Audits queued meanwhile are recorded with one status update per rule,
+// those not fitting in the queue are dropped, admission never waiting on it.
+type auditQueue struct {
+	client  client.Client
+	records chan auditRecord
+}
+
+var _ manager.Runnable = &auditQueue{}
+
+func newAuditQueue(c client.Client) *auditQueue {
+	return &auditQueue{
+		client:  c,
+		records: make(chan auditRecord, auditQueueSize),
+	}
+}
+
+// Add queues the audit to be recorded in the rule status, dropping it when the queue is full
+func (q *auditQueue) Add(rule kuberule.PodRule, audit kuberule.PodRuleAudit) {
+	record := auditRecord{
+		rule:  types.NamespacedName{Namespace: rule.Namespace, Name: rule.Name},
+		audit: audit,
+	}
+
+	select {
+	case q.records <- record:
+	default:
+		log.Info("audit queue is full, audit not recorded in rule status", "rule", record.rule, "pod", audit.Pod)
+	}
+}
+
+// Start implements manager.Runnable
+func (q *auditQueue) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case record := <-q.records:
+			batch := map[types.NamespacedName][]kuberule.PodRuleAudit{record.rule: {record.audit}}
+		drain:
+			for {
+				select {
+				case record := <-q.records:
+					batch[record.rule] = append(batch[record.rule], record.audit)
+				default:
+					break drain
+				}
+			}
+
+			for key, audits := range batch {
+				q.recordAudits(key, audits)
+			}
+		}
+	}
+}
+
+// recordAudits adds the audits, oldest first, to the rule status
+func (q *auditQueue) recordAudits(key types.NamespacedName, audits []kuberule.PodRuleAudit) {
+	ctx := context.Background()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &kuberule.PodRule{}
+		if err := q.client.Get(ctx, key, latest); err != nil {
+			return err
+		}
+
+		addAudits(&latest.Status, audits)
+		return q.client.Status().Update(ctx, latest)
+	})
+	if errors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		log.Error(err, "unable to record audits in rule status", "rule", key, "audits", len(audits))
+	}
+}
+
+// addAudits counts the audits, oldest first, and keeps the latest ones in the status
+func addAudits(status *kuberule.PodRuleStatus, audits []kuberule.PodRuleAudit) {
+	status.AuditedPods += int64(len(audits))
+
+	lastAudits := make([]kuberule.PodRuleAudit, 0, len(audits)+len(status.LastAudits))
+	for i := len(audits) - 1; i >= 0; i-- {
+		lastAudits = append(lastAudits, audits[i])
+	}
+	lastAudits = append(lastAudits, status.LastAudits...)
+	if len(lastAudits) > maxLastAudits {
+		lastAudits = lastAudits[:maxLastAudits]
+	}
+	status.LastAudits = lastAudits
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddAudits(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	audit := func(pod string) kuberule.PodRuleAudit {
+		return kuberule.PodRuleAudit{Pod: pod, Operation: "CREATE"}
+	}
+
+	status := &kuberule.PodRuleStatus{}
+	addAudits(status, []kuberule.PodRuleAudit{audit("web-1"), audit("web-2")})
+	g.Expect(status.AuditedPods).To(gomega.Equal(int64(2)))
+	g.Expect(status.LastAudits).To(gomega.Equal([]kuberule.PodRuleAudit{audit("web-2"), audit("web-1")}))
+
+	// latest audits are kept first, older ones being dropped past the limit
+	var audits []kuberule.PodRuleAudit
+	for i := 3; i <= 12; i++ {
+		audits = append(audits, audit(fmt.Sprintf("web-%d", i)))
+	}
+	addAudits(status, audits)
+	g.Expect(status.AuditedPods).To(gomega.Equal(int64(12)))
+	g.Expect(status.LastAudits).To(gomega.HaveLen(maxLastAudits))
+	g.Expect(status.LastAudits[0]).To(gomega.Equal(audit("web-12")))
+	g.Expect(status.LastAudits[maxLastAudits-1]).To(gomega.Equal(audit("web-3")))
+}
+
+func TestAuditQueue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	queue := newAuditQueue(nil)
+	rule := kuberule.PodRule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+
+	// admission never blocks on a full queue, audits past its size are dropped
+	for i := 0; i < auditQueueSize+10; i++ {
+		queue.Add(rule, kuberule.PodRuleAudit{Pod: fmt.Sprintf("web-%d", i)})
+	}
+	g.Expect(queue.records).To(gomega.HaveLen(auditQueueSize))
+	g.Expect((<-queue.records).rule.String()).To(gomega.Equal("default/web"))
+}
@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"path"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// forEachSelectedContainer calls fn with the containers of the pod matching the selector, all of them if nil
+func forEachSelectedContainer(pod *corev1.Pod, selector *kuberule.ContainerSelector, fn func(container *corev1.Container, containerType kuberule.ContainerType)) {
+	// TODO: ephemeral containers, missing from the Kubernetes 1.13 API (see the TODO section of the README)
+	for containerType, containers := range map[kuberule.ContainerType][]corev1.Container{
+		kuberule.ContainerTypeInit:    pod.Spec.InitContainers,
+		kuberule.ContainerTypeRegular: pod.Spec.Containers,
+	} {
+		for i := range containers {
+			if selector == nil || containerSelected(selector, &containers[i], containerType) {
+				fn(&containers[i], containerType)
+			}
+		}
+	}
+}
+
+// containerSelected checks whether the container matches all criteria of the selector
+func containerSelected(selector *kuberule.ContainerSelector, container *corev1.Container, containerType kuberule.ContainerType) bool {
+	if len(selector.Types) > 0 {
+		found := false
+		for _, selected := range selector.Types {
+			if selected == containerType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(selector.Names) > 0 && !matchesAnyPattern(selector.Names, container.Name) {
+		return false
+	}
+	if matchesAnyPattern(selector.ExcludeNames, container.Name) {
+		return false
+	}
+
+	if len(selector.Images) > 0 {
+		name, suffix := splitImage(container.Image)
+		if !matchesAnyPattern(selector.Images, container.Image, normalizeImageName(name)+suffix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAnyPattern checks whether any of the values matches any of the glob patterns
+func matchesAnyPattern(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			// patterns are validated with the rules
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
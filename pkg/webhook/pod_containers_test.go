@@ -0,0 +1,39 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestForEachSelectedContainer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "migrate", Image: "registry.internal/app:1.0"},
+			},
+			Containers: []corev1.Container{
+				{Name: "app", Image: "registry.internal/app:1.0"},
+				{Name: "nginx", Image: "nginx:1.15"},
+				{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.1.0"},
+			},
+		},
+	}
+	selected := func(selector *kuberule.ContainerSelector) []string {
+		names := []string{}
+		forEachSelectedContainer(pod, selector, func(container *corev1.Container, _ kuberule.ContainerType) {
+			names = append(names, container.Name)
+		})
+		return names
+	}
+
+	g.Expect(selected(nil)).To(gomega.ConsistOf("migrate", "app", "nginx", "istio-proxy"))
+	g.Expect(selected(&kuberule.ContainerSelector{ExcludeNames: []string{"istio-proxy"}})).To(gomega.ConsistOf("migrate", "app", "nginx"))
+	g.Expect(selected(&kuberule.ContainerSelector{Names: []string{"a*", "m*"}, Types: []kuberule.ContainerType{kuberule.ContainerTypeRegular}})).To(gomega.ConsistOf("app"))
+	g.Expect(selected(&kuberule.ContainerSelector{Types: []kuberule.ContainerType{kuberule.ContainerTypeInit}})).To(gomega.ConsistOf("migrate"))
+	g.Expect(selected(&kuberule.ContainerSelector{Images: []string{"docker.io/library/*"}})).To(gomega.ConsistOf("nginx"))
+	g.Expect(selected(&kuberule.ContainerSelector{Images: []string{"*/istio/proxyv2:*", "nginx:*"}})).To(gomega.ConsistOf("nginx", "istio-proxy"))
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerHubRegistry is the registry of images not specifying one
+const dockerHubRegistry = "docker.io"
+
+// mutateContainerImages rewrites the images and sets the pull policy of the selected containers
+func mutateContainerImages(pod *corev1.Pod, mutations *kuberule.PodMutations, strategies *kuberule.PodMutationStrategies) {
+	if len(mutations.ImageRegistryRewrites) == 0 && mutations.ImagePullPolicy == "" {
+		return
+	}
+
+	forEachSelectedContainer(pod, mutations.Containers, func(container *corev1.Container, _ kuberule.ContainerType) {
+		// the API server defaulted the policy from the image before it is rewritten, tags are kept anyway
+		if mutations.ImagePullPolicy != "" {
+			current := container.ImagePullPolicy
+			if current == "" || current == defaultPullPolicy(container.Image) || strategies.ImagePullPolicy == kuberule.MutationStrategyOverride {
+				container.ImagePullPolicy = mutations.ImagePullPolicy
+			}
+		}
+		container.Image = rewriteImage(container.Image, mutations.ImageRegistryRewrites)
+	})
+}
+
+// rewriteImage replaces the longest matching prefix of the image name, keeping its tag and digest
+func rewriteImage(image string, rewrites []kuberule.ImageRegistryRewrite) string {
+	name, suffix := splitImage(image)
+	name = normalizeImageName(name)
+
+	match := -1
+	for i, rewrite := range rewrites {
+		if rewrite.From == "" || !strings.HasPrefix(name, rewrite.From) {
+			continue
+		}
+		if match < 0 || len(rewrite.From) > len(rewrites[match].From) {
+			match = i
+		}
+	}
+	if match < 0 {
+		return image
+	}
+
+	return rewrites[match].To + strings.TrimPrefix(name, rewrites[match].From) + suffix
+}
+
+// splitImage splits the image into its name and its tag and digest, e.g. ":1.15@sha256:..."
+func splitImage(image string) (name, suffix string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	// colons before the last slash separate the registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, suffix = name[:i], name[i:]+suffix
+	}
+	return name, suffix
+}
+
+// normalizeImageName returns the fully qualified image name, e.g. docker.io/library/nginx for nginx
+func normalizeImageName(name string) string {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 {
+		return dockerHubRegistry + "/library/" + name
+	}
+	if !isRegistryHost(parts[0]) {
+		return dockerHubRegistry + "/" + name
+	}
+	return name
+}
+
+// isRegistryHost checks whether the first component of an image name is a registry rather than a repository
+func isRegistryHost(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// defaultPullPolicy returns the policy set by the API server on containers not having one
+func defaultPullPolicy(image string) corev1.PullPolicy {
+	_, suffix := splitImage(image)
+	if strings.HasPrefix(suffix, "@") {
+		return corev1.PullIfNotPresent
+	}
+
+	tag := strings.TrimPrefix(strings.SplitN(suffix, "@", 2)[0], ":")
+	if tag == "" || tag == "latest" {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
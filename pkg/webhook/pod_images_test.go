@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRewriteImage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rewrites := []kuberule.ImageRegistryRewrite{
+		{From: "docker.io/", To: "registry.internal/dockerhub/"},
+		{From: "docker.io/library/", To: "registry.internal/official/"},
+		{From: "localhost:5000/", To: "registry.internal/local/"},
+	}
+
+	for image, expected := range map[string]string{
+		"nginx":                              "registry.internal/official/nginx",
+		"nginx:1.15":                         "registry.internal/official/nginx:1.15",
+		"prom/prometheus:v2.7.1":             "registry.internal/dockerhub/prom/prometheus:v2.7.1",
+		"docker.io/prom/prometheus":          "registry.internal/dockerhub/prom/prometheus",
+		"localhost:5000/app:dev":             "registry.internal/local/app:dev",
+		"quay.io/coreos/etcd:v3.3":           "quay.io/coreos/etcd:v3.3",
+		"nginx@sha256:0123456789abcdef":      "registry.internal/official/nginx@sha256:0123456789abcdef",
+		"nginx:1.15@sha256:0123456789abcdef": "registry.internal/official/nginx:1.15@sha256:0123456789abcdef",
+	} {
+		g.Expect(rewriteImage(image, rewrites)).To(gomega.Equal(expected), "image %s", image)
+	}
+}
+
+func TestDefaultPullPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(defaultPullPolicy("nginx")).To(gomega.Equal(corev1.PullAlways))
+	g.Expect(defaultPullPolicy("nginx:latest")).To(gomega.Equal(corev1.PullAlways))
+	g.Expect(defaultPullPolicy("localhost:5000/app")).To(gomega.Equal(corev1.PullAlways))
+	g.Expect(defaultPullPolicy("nginx:1.15")).To(gomega.Equal(corev1.PullIfNotPresent))
+	g.Expect(defaultPullPolicy("nginx@sha256:0123456789abcdef")).To(gomega.Equal(corev1.PullIfNotPresent))
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// defaultTerminationGracePeriodSeconds is set by the API server on pods not having one
+const defaultTerminationGracePeriodSeconds int64 = 30
+
+// mutateTerminationGracePeriod sets the grace period of the pod using the strategy.
+// Merging keeps the longest period, shortening it could interrupt a graceful shutdown.
+func mutateTerminationGracePeriod(pod *corev1.Pod, period int64, strategy kuberule.MutationStrategy) {
+	current := pod.Spec.TerminationGracePeriodSeconds
+	switch strategy {
+	case kuberule.MutationStrategyOverride:
+	case kuberule.MutationStrategyKeepExisting:
+		if current != nil && *current != defaultTerminationGracePeriodSeconds {
+			return
+		}
+	default:
+		if current != nil && *current >= period {
+			return
+		}
+	}
+	pod.Spec.TerminationGracePeriodSeconds = &period
+}
+
+// addPreStopHooks adds the hook to the targeted containers not having a preStop hook already.
+// Init containers don't support lifecycle hooks.
+func addPreStopHooks(pod *corev1.Pod, preStop *kuberule.ContainerPreStop, selector *kuberule.ContainerSelector) {
+	names := sets.NewString(preStop.Containers...)
+	forEachSelectedContainer(pod, selector, func(container *corev1.Container, containerType kuberule.ContainerType) {
+		if containerType != kuberule.ContainerTypeRegular || (names.Len() > 0 && !names.Has(container.Name)) {
+			return
+		}
+		if container.Lifecycle == nil {
+			container.Lifecycle = &corev1.Lifecycle{}
+		}
+		if container.Lifecycle.PreStop == nil {
+			container.Lifecycle.PreStop = preStop.Handler.DeepCopy()
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMutateTerminationGracePeriod(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	period := func(seconds int64) *int64 {
+		return &seconds
+	}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{TerminationGracePeriodSeconds: period(90)}}
+	mutateTerminationGracePeriod(pod, 60, kuberule.MutationStrategyMerge)
+	g.Expect(pod.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(period(90)))
+	mutateTerminationGracePeriod(pod, 120, kuberule.MutationStrategyMerge)
+	g.Expect(pod.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(period(120)))
+	mutateTerminationGracePeriod(pod, 60, kuberule.MutationStrategyKeepExisting)
+	g.Expect(pod.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(period(120)))
+	mutateTerminationGracePeriod(pod, 60, kuberule.MutationStrategyOverride)
+	g.Expect(pod.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(period(60)))
+
+	// the API server default is not a choice of the pod owner
+	pod.Spec.TerminationGracePeriodSeconds = period(defaultTerminationGracePeriodSeconds)
+	mutateTerminationGracePeriod(pod, 10, kuberule.MutationStrategyKeepExisting)
+	g.Expect(pod.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(period(10)))
+}
+
+func TestAddPreStopHooks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	sleep := corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"sleep", "15"}}}
+	existing := &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"nginx", "-s", "quit"}}}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app"},
+				{Name: "nginx", Lifecycle: &corev1.Lifecycle{PreStop: existing}},
+				{Name: "istio-proxy"},
+			},
+		},
+	}
+
+	addPreStopHooks(pod, &kuberule.ContainerPreStop{Containers: []string{"app", "nginx"}, Handler: sleep}, nil)
+	g.Expect(pod.Spec.Containers[0].Lifecycle.PreStop).To(gomega.Equal(&sleep))
+	g.Expect(pod.Spec.Containers[1].Lifecycle.PreStop).To(gomega.Equal(existing))
+	g.Expect(pod.Spec.Containers[2].Lifecycle).To(gomega.BeNil())
+
+	addPreStopHooks(pod, &kuberule.ContainerPreStop{Handler: sleep}, &kuberule.ContainerSelector{ExcludeNames: []string{"istio-*"}})
+	g.Expect(pod.Spec.Containers[2].Lifecycle).To(gomega.BeNil())
+
+	addPreStopHooks(pod, &kuberule.ContainerPreStop{Handler: sleep}, nil)
+	g.Expect(pod.Spec.Containers[2].Lifecycle.PreStop).To(gomega.Equal(&sleep))
+}
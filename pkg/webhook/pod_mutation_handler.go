@@ -3,19 +3,25 @@ package webhook
 import (
 	"context"
 	"net/http"
-	"sort"
+	"strings"
+	"time"
 
-	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1alpha1"
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	admissiontypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
 )
 
 type podMutationHandler struct {
-	client  client.Client
-	decoder admissiontypes.Decoder
+	client   client.Client
+	decoder  admissiontypes.Decoder
+	recorder record.EventRecorder
+	audits   *auditQueue
 }
 
 var _ admission.Handler = &podMutationHandler{} // Implements admission.Handler.
@@ -29,106 +35,338 @@ func (a *podMutationHandler) Handle(ctx context.Context, req admissiontypes.Requ
 		return admission.ErrorResponse(http.StatusBadRequest, err)
 	}
 	clone := pod.DeepCopy()
+	operation := req.AdmissionRequest.Operation
 
 	log.Info("handling pod",
 		"request.namespace", req.AdmissionRequest.Namespace,
-		"request.operation", req.AdmissionRequest.Operation,
+		"request.operation", operation,
 		"pod.name", pod.Name,
 		"pod.generateName", pod.GenerateName,
 	)
 
-	// Get matching rules sorted by ApplyOrder
-	podRuleList := &kuberule.PodRuleList{}
-	listOptions := client.InNamespace(req.AdmissionRequest.Namespace)
-	if err := a.client.List(ctx, listOptions, podRuleList); err != nil {
+	// Rules were already applied when the pod was created, re-applying them
+	// to a pod bound to a node would only produce rejected updates.
+	if operation == admissionv1beta1.Update && pod.Spec.NodeName != "" {
+		log.Info("skipping scheduled pod",
+			"pod.name", pod.Name,
+			"pod.nodeName", pod.Spec.NodeName,
+		)
+		return admission.PatchResponse(pod, clone)
+	}
+
+	// dry run requests don't record events, metrics nor audits
+	dryRun := isDryRun(req)
+	recorder := a.recorder
+	if dryRun {
+		recorder = nopRecorder{}
+	}
+	evaluated := func(rule kuberule.PodRule, result string) {
+		if !dryRun {
+			podRuleEvaluations.WithLabelValues(rule.Namespace, rule.Name, result).Inc()
+		}
+	}
+
+	rules, err := listPodRules(ctx, a.client, recorder, pod, req.AdmissionRequest.Namespace)
+	if err != nil {
 		return admission.ErrorResponse(http.StatusInternalServerError, err)
 	}
-	sort.Slice(podRuleList.Items, func(i, j int) bool {
-		return podRuleList.Items[i].Spec.ApplyOrder < podRuleList.Items[j].Spec.ApplyOrder
-	})
-	for _, rule := range podRuleList.Items {
-		// check matching pods, skip if doesn't match
-		podSelector := labels.Set(rule.Spec.Selector.MatchLabels).AsSelector()
-		if !podSelector.Matches(labels.Set(pod.Labels)) {
+
+	var policies []kuberule.PodRulePolicy
+	if len(rules) > 0 {
+		policies, err = listPodRulePolicies(ctx, a.client, req.AdmissionRequest.Namespace)
+		if err != nil {
+			return admission.ErrorResponse(http.StatusInternalServerError, err)
+		}
+	}
+
+	auditedRules := []string{}
+	appliedRules := map[string]string{}
+	now := time.Now()
+	for _, rule := range rules {
+		if applies, result := evaluatePodRule(rule, pod, now); !applies {
+			evaluated(rule, result)
 			continue
 		}
 
-		// apply mutations
-		err = a.mutatePodsFn(ctx, clone, rule)
+		mutations, result, err := resolveAllowedMutations(ctx, a.client, recorder, &rule, pod, policies)
 		if err != nil {
 			return admission.ErrorResponse(http.StatusInternalServerError, err)
 		}
+		if mutations == nil {
+			evaluated(rule, result)
+			continue
+		}
+		rule.Spec.Mutations = *mutations
+
+		switch rule.Spec.Mode {
+		case kuberule.PodRuleModeAudit:
+			// compute mutations on a separate copy, only recording them
+			audited := clone.DeepCopy()
+			if err := a.mutatePodsFn(ctx, audited, rule, operation); err != nil {
+				return admission.ErrorResponse(http.StatusInternalServerError, err)
+			}
+			patches := admission.PatchResponse(clone, audited).Patches
+			if len(patches) == 0 {
+				continue
+			}
+			if !dryRun {
+				a.auditPod(rule, pod, string(operation), patches)
+			}
+			auditedRules = append(auditedRules, rule.Name)
+
+		default:
+			// apply mutations
+			err = a.mutatePodsFn(ctx, clone, rule, operation)
+			if err != nil {
+				return admission.ErrorResponse(http.StatusInternalServerError, err)
+			}
+			evaluated(rule, evaluationResultApplied)
+			appliedRules[rule.Name] = mutations.Hash()
+		}
+	}
+
+	if operation == admissionv1beta1.Create {
+		if err := a.reconcileServiceAccountToken(ctx, req.AdmissionRequest.Namespace, pod, clone); err != nil {
+			return admission.ErrorResponse(http.StatusInternalServerError, err)
+		}
+	}
+
+	// record the applied rules so the controller can find pods created before rule changes,
+	// rules being nil for skipped pods
+	if operation == admissionv1beta1.Create && rules != nil {
+		if clone.Annotations == nil {
+			clone.Annotations = map[string]string{}
+		}
+		clone.Annotations[kuberule.AnnotationAppliedRules] = kuberule.FormatAppliedRules(appliedRules)
+	}
+
+	// let pod owners know which audit mode rules would have mutated the pod
+	if len(auditedRules) > 0 {
+		if clone.Annotations == nil {
+			clone.Annotations = map[string]string{}
+		}
+		clone.Annotations[kuberule.AnnotationAuditedRules] = strings.Join(auditedRules, ",")
 	}
 
 	// create patches
 	return admission.PatchResponse(pod, clone)
 }
 
-// mutatePodsFn mutates the given pod
-func (a *podMutationHandler) mutatePodsFn(ctx context.Context, pod *corev1.Pod, rule kuberule.PodRule) error {
+// isDryRun checks whether the request is a dry run, whose side effects must be skipped
+func isDryRun(req admissiontypes.Request) bool {
+	return req.AdmissionRequest.DryRun != nil && *req.AdmissionRequest.DryRun
+}
+
+// nopRecorder drops the events recorded while handling dry run requests
+type nopRecorder struct{}
+
+func (nopRecorder) Event(object runtime.Object, eventtype, reason, message string) {}
+
+func (nopRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+func (nopRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+func (nopRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+// mutatePodsFn mutates the given pod according to the rule strategies.
+// Every mutation is idempotent so the webhook can safely be reinvoked.
+// On UPDATE, only mutations Kubernetes allows on existing pods are applied:
+// annotations, labels and additional tolerations, merged into the existing ones.
+func (a *podMutationHandler) mutatePodsFn(ctx context.Context, pod *corev1.Pod, rule kuberule.PodRule, operation admissionv1beta1.Operation) error {
 	log.Info("applying mutations to pod",
 		"pod", &pod,
 		"rule", rule,
+		"operation", operation,
 	)
 	mutations := rule.Spec.Mutations
+	strategies := rule.Spec.Mutations.Strategies
+	strategies.Default()
 
-	// merge with existing annotations
-	if mutations.Annotations == nil {
-		mutations.Annotations = map[string]string{}
+	// metadata set by controllers, e.g. the pod-template-hash label owning the pod to its
+	// ReplicaSet, isn't overridden on update, and existing tolerations can't be removed
+	annotationsStrategy, labelsStrategy, tolerationsStrategy := strategies.Annotations, strategies.Labels, strategies.Tolerations
+	if operation == admissionv1beta1.Update {
+		if annotationsStrategy == kuberule.MutationStrategyOverride {
+			annotationsStrategy = kuberule.MutationStrategyMerge
+		}
+		if labelsStrategy == kuberule.MutationStrategyOverride {
+			labelsStrategy = kuberule.MutationStrategyMerge
+		}
+		tolerationsStrategy = kuberule.MutationStrategyMerge
 	}
-	if pod.Annotations == nil {
-		pod.Annotations = map[string]string{}
+
+	pod.Annotations = mutateStringMap(pod.Annotations, mutations.Annotations, annotationsStrategy)
+	pod.Labels = mutateStringMap(pod.Labels, mutations.Labels, labelsStrategy)
+
+	if len(mutations.Tolerations) > 0 {
+		switch tolerationsStrategy {
+		case kuberule.MutationStrategyOverride:
+			pod.Spec.Tolerations = append([]corev1.Toleration{}, mutations.Tolerations...)
+		case kuberule.MutationStrategyKeepExisting:
+			if len(pod.Spec.Tolerations) == 0 {
+				pod.Spec.Tolerations = append([]corev1.Toleration{}, mutations.Tolerations...)
+			}
+		default:
+			for _, toleration := range mutations.Tolerations {
+				found := false
+				for _, existing := range pod.Spec.Tolerations {
+					if existing.MatchToleration(&toleration) {
+						found = true
+						break
+					}
+				}
+
+				if !found {
+					pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+				}
+			}
+		}
 	}
-	for key, val := range mutations.Annotations {
-		pod.Annotations[key] = val
+
+	// the rest of the pod spec is immutable after creation
+	if operation == admissionv1beta1.Update {
+		return nil
 	}
 
-	// apply affinity only if not already exists
-	if pod.Spec.Affinity != nil && mutations.Affinity != nil {
-		pod.Spec.Affinity = mutations.Affinity
+	// affinity can't be merged
+	if mutations.Affinity != nil {
+		if pod.Spec.Affinity == nil || strategies.Affinity == kuberule.MutationStrategyOverride {
+			pod.Spec.Affinity = mutations.Affinity.DeepCopy()
+		}
 	}
 
-	// apply nodeSelector only if not already exists
-	if pod.Spec.NodeSelector == nil {
-		pod.Spec.NodeSelector = map[string]string{}
+	pod.Spec.NodeSelector = mutateStringMap(pod.Spec.NodeSelector, mutations.NodeSelector, strategies.NodeSelector)
+
+	if len(mutations.ImagePullSecrets) > 0 {
+		switch strategies.ImagePullSecrets {
+		case kuberule.MutationStrategyOverride:
+			pod.Spec.ImagePullSecrets = append([]corev1.LocalObjectReference{}, mutations.ImagePullSecrets...)
+		case kuberule.MutationStrategyKeepExisting:
+			if len(pod.Spec.ImagePullSecrets) == 0 {
+				pod.Spec.ImagePullSecrets = append([]corev1.LocalObjectReference{}, mutations.ImagePullSecrets...)
+			}
+		default:
+			for _, secret := range mutations.ImagePullSecrets {
+				found := false
+				for _, existing := range pod.Spec.ImagePullSecrets {
+					if secret.Name == existing.Name {
+						found = true
+						break
+					}
+				}
+
+				if !found {
+					pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, secret)
+				}
+			}
+		}
 	}
-	if len(pod.Spec.NodeSelector) == 0 {
-		for key, val := range mutations.NodeSelector {
-			pod.Spec.NodeSelector[key] = val
+
+	if mutations.ServiceAccountName != "" {
+		// the ServiceAccount admission plugin sets the default service account on pods not having one
+		current := pod.Spec.ServiceAccountName
+		if current == "" || current == "default" || strategies.ServiceAccountName == kuberule.MutationStrategyOverride {
+			pod.Spec.ServiceAccountName = mutations.ServiceAccountName
+			pod.Spec.DeprecatedServiceAccount = mutations.ServiceAccountName
 		}
 	}
 
-	// append to existing tolerations
-	if pod.Spec.Tolerations == nil {
-		pod.Spec.Tolerations = []corev1.Toleration{}
+	if mutations.AutomountServiceAccountToken != nil {
+		if pod.Spec.AutomountServiceAccountToken == nil || strategies.AutomountServiceAccountToken == kuberule.MutationStrategyOverride {
+			automount := *mutations.AutomountServiceAccountToken
+			pod.Spec.AutomountServiceAccountToken = &automount
+		}
 	}
-	if mutations.Tolerations != nil {
-		for _, toleration := range mutations.Tolerations {
-			pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+
+	if mutations.DNSPolicy != "" {
+		// the API server defaults pods to ClusterFirst
+		current := pod.Spec.DNSPolicy
+		if current == "" || current == corev1.DNSClusterFirst || strategies.DNSPolicy == kuberule.MutationStrategyOverride {
+			pod.Spec.DNSPolicy = mutations.DNSPolicy
 		}
 	}
 
-	// append imagePullSecrets
-	if pod.Spec.ImagePullSecrets == nil {
-		pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{}
+	if mutations.DNSConfig != nil {
+		switch strategies.DNSConfig {
+		case kuberule.MutationStrategyOverride:
+			pod.Spec.DNSConfig = mutations.DNSConfig.DeepCopy()
+		case kuberule.MutationStrategyKeepExisting:
+			if pod.Spec.DNSConfig == nil {
+				pod.Spec.DNSConfig = mutations.DNSConfig.DeepCopy()
+			}
+		default:
+			pod.Spec.DNSConfig = kuberule.MergePodDNSConfig(pod.Spec.DNSConfig, mutations.DNSConfig)
+		}
 	}
-	if mutations.ImagePullSecrets != nil {
-		for _, secret := range mutations.ImagePullSecrets {
-			found := false
-			for _, existing := range pod.Spec.ImagePullSecrets {
-				if secret.Name == existing.Name {
-					found = true
-					break
-				}
+
+	if len(mutations.HostAliases) > 0 {
+		switch strategies.HostAliases {
+		case kuberule.MutationStrategyOverride:
+			pod.Spec.HostAliases = append([]corev1.HostAlias{}, mutations.HostAliases...)
+		case kuberule.MutationStrategyKeepExisting:
+			if len(pod.Spec.HostAliases) == 0 {
+				pod.Spec.HostAliases = append([]corev1.HostAlias{}, mutations.HostAliases...)
 			}
+		default:
+			pod.Spec.HostAliases = kuberule.MergeHostAliases(pod.Spec.HostAliases, mutations.HostAliases)
+		}
+	}
 
-			if !found {
-				pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, secret)
+	if mutations.TerminationGracePeriodSeconds != nil {
+		mutateTerminationGracePeriod(pod, *mutations.TerminationGracePeriodSeconds, strategies.TerminationGracePeriodSeconds)
+	}
+
+	if mutations.PreStop != nil {
+		addPreStopHooks(pod, mutations.PreStop, mutations.Containers)
+	}
+
+	if len(mutations.ReadinessGates) > 0 {
+		switch strategies.ReadinessGates {
+		case kuberule.MutationStrategyOverride:
+			pod.Spec.ReadinessGates = append([]corev1.PodReadinessGate{}, mutations.ReadinessGates...)
+		case kuberule.MutationStrategyKeepExisting:
+			if len(pod.Spec.ReadinessGates) == 0 {
+				pod.Spec.ReadinessGates = append([]corev1.PodReadinessGate{}, mutations.ReadinessGates...)
 			}
+		default:
+			pod.Spec.ReadinessGates = kuberule.MergeReadinessGates(pod.Spec.ReadinessGates, mutations.ReadinessGates)
 		}
 	}
 
+	mutateContainerImages(pod, &mutations, &strategies)
+
+	if len(mutations.Volumes) > 0 || len(mutations.VolumeMounts) > 0 {
+		addVolumes(pod, &mutations)
+	}
+
 	// TODO: add more mutations here
 
 	return nil
 }
+
+// mutateStringMap applies the values on the existing map using the strategy, returning the result
+func mutateStringMap(existing, values map[string]string, strategy kuberule.MutationStrategy) map[string]string {
+	if len(values) == 0 {
+		return existing
+	}
+
+	switch strategy {
+	case kuberule.MutationStrategyOverride:
+		existing = map[string]string{}
+	case kuberule.MutationStrategyKeepExisting:
+		if len(existing) > 0 {
+			return existing
+		}
+	}
+
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for key, val := range values {
+		existing[key] = val
+	}
+	return existing
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	fuzz "github.com/google/gofuzz"
+	"github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	admissiontypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// jsonDecoder decodes the object of admission requests
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(req admissiontypes.Request, obj runtime.Object) error {
+	return json.Unmarshal(req.AdmissionRequest.Object.Raw, obj)
+}
+
+// fuzzPod only fills the parts of the pod touched by mutations
+func fuzzPod(f *fuzz.Fuzzer) *corev1.Pod {
+	pod := &corev1.Pod{}
+	f.Fuzz(&pod.Annotations)
+	f.Fuzz(&pod.Labels)
+	f.Fuzz(&pod.Spec.Affinity)
+	f.Fuzz(&pod.Spec.NodeSelector)
+	f.Fuzz(&pod.Spec.Tolerations)
+	f.Fuzz(&pod.Spec.ImagePullSecrets)
+	f.Fuzz(&pod.Spec.ServiceAccountName)
+	f.Fuzz(&pod.Spec.AutomountServiceAccountToken)
+	f.Fuzz(&pod.Spec.DNSPolicy)
+	f.Fuzz(&pod.Spec.DNSConfig)
+	f.Fuzz(&pod.Spec.HostAliases)
+	f.Fuzz(&pod.Spec.TerminationGracePeriodSeconds)
+	f.Fuzz(&pod.Spec.InitContainers)
+	f.Fuzz(&pod.Spec.Containers)
+	f.Fuzz(&pod.Spec.ReadinessGates)
+	f.Fuzz(&pod.Spec.Volumes)
+	return pod
+}
+
+func fuzzRules(f *fuzz.Fuzzer) []kuberule.PodRule {
+	var mutations []kuberule.PodMutations
+	f.Fuzz(&mutations)
+
+	rules := make([]kuberule.PodRule, len(mutations))
+	for i := range mutations {
+		rules[i].Spec.ApplyOrder = int32(i)
+		rules[i].Spec.Mutations = mutations[i]
+	}
+	return rules
+}
+
+// fuzzMutationStrategy picks one of the valid strategies, or none to use the default
+func fuzzMutationStrategy(strategy *kuberule.MutationStrategy, c fuzz.Continue) {
+	strategies := []kuberule.MutationStrategy{
+		"",
+		kuberule.MutationStrategyKeepExisting,
+		kuberule.MutationStrategyMerge,
+		kuberule.MutationStrategyOverride,
+	}
+	*strategy = strategies[c.Intn(len(strategies))]
+}
+
+func newFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0.3).NumElements(0, 3).Funcs(fuzzMutationStrategy)
+}
+
+func TestMutatePodsIdempotent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	handler := &podMutationHandler{}
+	f := newFuzzer()
+
+	applyAll := func(pod *corev1.Pod, rules []kuberule.PodRule, operation admissionv1beta1.Operation) {
+		for _, rule := range rules {
+			g.Expect(handler.mutatePodsFn(context.TODO(), pod, rule, operation)).NotTo(gomega.HaveOccurred())
+		}
+	}
+
+	for _, operation := range []admissionv1beta1.Operation{admissionv1beta1.Create, admissionv1beta1.Update} {
+		for i := 0; i < 500; i++ {
+			pod := fuzzPod(f)
+			rules := fuzzRules(f)
+
+			once := pod.DeepCopy()
+			applyAll(once, rules, operation)
+
+			twice := once.DeepCopy()
+			applyAll(twice, rules, operation)
+
+			g.Expect(twice).To(gomega.Equal(once), "operation %s", operation)
+		}
+	}
+}
+
+func TestMutatePodsUpdateKeepsImmutableFields(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	handler := &podMutationHandler{}
+	f := newFuzzer()
+
+	for i := 0; i < 500; i++ {
+		pod := fuzzPod(f)
+		rules := fuzzRules(f)
+
+		mutated := pod.DeepCopy()
+		for _, rule := range rules {
+			g.Expect(handler.mutatePodsFn(context.TODO(), mutated, rule, admissionv1beta1.Update)).NotTo(gomega.HaveOccurred())
+		}
+
+		g.Expect(mutated.Spec.Affinity).To(gomega.Equal(pod.Spec.Affinity))
+		g.Expect(mutated.Spec.NodeSelector).To(gomega.Equal(pod.Spec.NodeSelector))
+		g.Expect(mutated.Spec.ImagePullSecrets).To(gomega.Equal(pod.Spec.ImagePullSecrets))
+		g.Expect(mutated.Spec.ServiceAccountName).To(gomega.Equal(pod.Spec.ServiceAccountName))
+		g.Expect(mutated.Spec.AutomountServiceAccountToken).To(gomega.Equal(pod.Spec.AutomountServiceAccountToken))
+		g.Expect(mutated.Spec.DNSPolicy).To(gomega.Equal(pod.Spec.DNSPolicy))
+		g.Expect(mutated.Spec.DNSConfig).To(gomega.Equal(pod.Spec.DNSConfig))
+		g.Expect(mutated.Spec.HostAliases).To(gomega.Equal(pod.Spec.HostAliases))
+		g.Expect(mutated.Spec.TerminationGracePeriodSeconds).To(gomega.Equal(pod.Spec.TerminationGracePeriodSeconds))
+		g.Expect(mutated.Spec.InitContainers).To(gomega.Equal(pod.Spec.InitContainers))
+		g.Expect(mutated.Spec.Containers).To(gomega.Equal(pod.Spec.Containers))
+		g.Expect(mutated.Spec.ReadinessGates).To(gomega.Equal(pod.Spec.ReadinessGates))
+		g.Expect(mutated.Spec.Volumes).To(gomega.Equal(pod.Spec.Volumes))
+		for i, toleration := range pod.Spec.Tolerations {
+			g.Expect(mutated.Spec.Tolerations[i]).To(gomega.Equal(toleration))
+		}
+	}
+}
+
+func TestMutatePodsUpdateKeepsMetadata(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	handler := &podMutationHandler{}
+	pod := &corev1.Pod{}
+	pod.Labels = map[string]string{"app": "foo", "pod-template-hash": "5d8f7b"}
+	pod.Annotations = map[string]string{"owner": "team-a"}
+	rule := kuberule.PodRule{Spec: kuberule.PodRuleSpec{Mutations: kuberule.PodMutations{
+		Labels:      map[string]string{"env": "prod"},
+		Annotations: map[string]string{"team": "b"},
+		Strategies: kuberule.PodMutationStrategies{
+			Labels:      kuberule.MutationStrategyOverride,
+			Annotations: kuberule.MutationStrategyOverride,
+		},
+	}}}
+
+	updated := pod.DeepCopy()
+	g.Expect(handler.mutatePodsFn(context.TODO(), updated, rule, admissionv1beta1.Update)).NotTo(gomega.HaveOccurred())
+	g.Expect(updated.Labels).To(gomega.Equal(map[string]string{"app": "foo", "pod-template-hash": "5d8f7b", "env": "prod"}))
+	g.Expect(updated.Annotations).To(gomega.Equal(map[string]string{"owner": "team-a", "team": "b"}))
+
+	created := pod.DeepCopy()
+	g.Expect(handler.mutatePodsFn(context.TODO(), created, rule, admissionv1beta1.Create)).NotTo(gomega.HaveOccurred())
+	g.Expect(created.Labels).To(gomega.Equal(map[string]string{"env": "prod"}))
+}
+
+func TestHandleDryRun(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	c := &podRulesClient{
+		namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "staging",
+			// denied by the policy, reported by an event
+			Annotations: map[string]string{kuberule.AnnotationMutations: `{"nodeSelector": {"env": "staging"}}`},
+		}},
+		rules: []kuberule.PodRule{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "staging", Name: "audited"},
+			Spec: kuberule.PodRuleSpec{
+				MatchAll:  true,
+				Mode:      kuberule.PodRuleModeAudit,
+				Mutations: kuberule.PodMutations{Labels: map[string]string{"team": "platform"}},
+			},
+		}},
+		policies: []kuberule.PodRulePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "labels-only"},
+			Spec:       kuberule.PodRulePolicySpec{AllowedMutations: []string{"labels"}},
+		}},
+	}
+	recorder := record.NewFakeRecorder(10)
+	handler := &podMutationHandler{client: c, decoder: jsonDecoder{}, recorder: recorder, audits: newAuditQueue(c)}
+
+	raw, err := json.Marshal(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "staging"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	request := func(dryRun bool) admissiontypes.Request {
+		return admissiontypes.Request{AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+			Namespace: "staging",
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			DryRun:    &dryRun,
+		}}
+	}
+
+	// neither events nor audits are recorded for dry run requests
+	handler.Handle(context.TODO(), request(true))
+	g.Expect(recorder.Events).To(gomega.BeEmpty())
+	g.Expect(handler.audits.records).To(gomega.BeEmpty())
+
+	handler.Handle(context.TODO(), request(false))
+	g.Expect(recorder.Events).To(gomega.HaveLen(2))
+	g.Expect(handler.audits.records).To(gomega.HaveLen(1))
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// namespacePodRule returns the implicit rule made of the mutations declared by the namespace annotations,
+// nil when the namespace doesn't declare any. It is applied before all PodRules of the namespace.
+func namespacePodRule(namespace *corev1.Namespace) (*kuberule.PodRule, error) {
+	annotations := namespace.Annotations
+	mutations := &kuberule.PodMutations{}
+	declared := false
+
+	if value, ok := annotations[kuberule.AnnotationMutations]; ok {
+		if err := json.Unmarshal([]byte(value), mutations); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %s", kuberule.AnnotationMutations, err)
+		}
+		declared = true
+	}
+
+	if value, ok := annotations[kuberule.AnnotationNodeSelector]; ok {
+		nodeSelector, err := labels.ConvertSelectorToLabelsMap(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %s", kuberule.AnnotationNodeSelector, err)
+		}
+		mutations.Merge(&kuberule.PodMutations{NodeSelector: nodeSelector})
+		declared = true
+	}
+
+	if value, ok := annotations[kuberule.AnnotationTolerations]; ok {
+		tolerations := []corev1.Toleration{}
+		if err := json.Unmarshal([]byte(value), &tolerations); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %s", kuberule.AnnotationTolerations, err)
+		}
+		mutations.Merge(&kuberule.PodMutations{Tolerations: tolerations})
+		declared = true
+	}
+
+	if !declared {
+		return nil, nil
+	}
+
+	if errs := validatePodMutations(mutations, field.NewPath("metadata", "annotations")); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid mutations annotations: %s", errs.ToAggregate())
+	}
+
+	return &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace.Name,
+			Name:      kuberule.NamespaceRuleName,
+		},
+		Spec: kuberule.PodRuleSpec{
+			ApplyOrder: math.MinInt32,
+			Mutations:  *mutations,
+		},
+	}, nil
+}
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespacePodRule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	namespace := func(annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging", Annotations: annotations}}
+	}
+
+	rule, err := namespacePodRule(namespace(nil))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rule).To(gomega.BeNil())
+
+	rule, err = namespacePodRule(namespace(map[string]string{
+		kuberule.AnnotationMutations:    `{"labels": {"env": "staging"}, "nodeSelector": {"env": "production"}}`,
+		kuberule.AnnotationNodeSelector: "env=staging,kubernetes.io/role=app",
+		kuberule.AnnotationTolerations:  `[{"key": "env", "operator": "Equal", "value": "staging", "effect": "NoSchedule"}]`,
+	}))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rule.Namespace).To(gomega.Equal("staging"))
+	g.Expect(rule.Name).To(gomega.Equal(kuberule.NamespaceRuleName))
+	g.Expect(rule.Spec.Mutations.Labels).To(gomega.Equal(map[string]string{"env": "staging"}))
+	g.Expect(rule.Spec.Mutations.NodeSelector).To(gomega.Equal(map[string]string{"env": "staging", "kubernetes.io/role": "app"}))
+	g.Expect(rule.Spec.Mutations.Tolerations).To(gomega.Equal([]corev1.Toleration{
+		{Key: "env", Operator: corev1.TolerationOpEqual, Value: "staging", Effect: corev1.TaintEffectNoSchedule},
+	}))
+
+	_, err = namespacePodRule(namespace(map[string]string{kuberule.AnnotationTolerations: "env=staging"}))
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = namespacePodRule(namespace(map[string]string{kuberule.AnnotationNodeSelector: "not a label=staging"}))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
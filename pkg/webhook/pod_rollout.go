@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"hash/fnv"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podOwnerKey identifies the group of pods sharing the same rollout decision:
+// the controlling owner if any, otherwise the generateName or name of the pod.
+func podOwnerKey(pod *corev1.Pod) string {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return owner.Kind + "/" + owner.Name
+	}
+	if pod.GenerateName != "" {
+		return pod.GenerateName
+	}
+	return pod.Name
+}
+
+// inRollout checks whether the rule rollout includes the given pod.
+// The decision is a stable hash of the rule and pod owner, so it doesn't change between pods of a ReplicaSet.
+func inRollout(rule kuberule.PodRule, pod *corev1.Pod) bool {
+	if rule.Spec.Rollout == nil {
+		return true
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(rule.Namespace + "/" + rule.Name + "/" + podOwnerKey(pod)))
+	return int32(hash.Sum32()%100) < rule.Spec.Rollout.Percentage
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInRollout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	controller := true
+
+	podOf := func(replicaSet, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:         name,
+			GenerateName: replicaSet + "-",
+			OwnerReferences: []metav1.OwnerReference{{
+				Kind:       "ReplicaSet",
+				Name:       replicaSet,
+				Controller: &controller,
+			}},
+		}}
+	}
+	ruleWithPercentage := func(percentage int32) kuberule.PodRule {
+		return kuberule.PodRule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary"},
+			Spec: kuberule.PodRuleSpec{
+				Rollout: &kuberule.PodRuleRollout{Percentage: percentage},
+			},
+		}
+	}
+
+	g.Expect(inRollout(kuberule.PodRule{}, podOf("app", "app-1"))).To(gomega.BeTrue())
+	g.Expect(inRollout(ruleWithPercentage(100), podOf("app", "app-1"))).To(gomega.BeTrue())
+	g.Expect(inRollout(ruleWithPercentage(0), podOf("app", "app-1"))).To(gomega.BeFalse())
+
+	included := 0
+	for i := 0; i < 1000; i++ {
+		replicaSet := fmt.Sprintf("app-%d", i)
+		rule := ruleWithPercentage(30)
+
+		decision := inRollout(rule, podOf(replicaSet, replicaSet+"-a"))
+		g.Expect(inRollout(rule, podOf(replicaSet, replicaSet+"-b"))).To(gomega.Equal(decision))
+		if decision {
+			included++
+		}
+	}
+	g.Expect(included).To(gomega.BeNumerically("~", 300, 60))
+}
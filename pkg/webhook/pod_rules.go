@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/config"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// listPodRules returns the enabled rules of the namespace matching the pod, sorted by applyOrder,
+// preceded by the rule declared by the namespace annotations if any and allowed by the PodRulePolicies.
+// Opt-out and opt-in annotations of both the pod and its namespace are honored, skipped pods getting nil rules.
+func listPodRules(ctx context.Context, c client.Client, recorder record.EventRecorder, pod *corev1.Pod, namespaceName string) ([]kuberule.PodRule, error) {
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace); err != nil {
+		return nil, err
+	}
+	if skip, reason := skipPod(pod, namespace); skip {
+		log.Info("skipping pod",
+			"pod.name", pod.Name,
+			"pod.generateName", pod.GenerateName,
+			"reason", reason,
+		)
+		return nil, nil
+	}
+	excludedRules := podrules.ExcludedRules(pod, namespace)
+
+	// Get matching rules sorted by ApplyOrder
+	podRuleList := &kuberule.PodRuleList{}
+	listOptions := client.InNamespace(namespaceName)
+	if err := c.List(ctx, listOptions, podRuleList); err != nil {
+		return nil, err
+	}
+
+	rules := []kuberule.PodRule{}
+
+	// namespace annotations make the lowest order rule, matching all pods
+	namespaceRule, err := namespacePodRule(namespace)
+	if err != nil {
+		log.Error(err, "skipping namespace rule", "namespace", namespaceName)
+	} else if namespaceRule != nil && !excludedRules.Has(namespaceRule.Name) {
+		// namespace annotations can't set what PodRules of the namespace aren't allowed to
+		policies, err := listPodRulePolicies(ctx, c, namespaceName)
+		if err != nil {
+			return nil, err
+		}
+		if errs := validatePodRulePolicies(policies, &namespaceRule.Spec.Mutations, field.NewPath("metadata", "annotations")); len(errs) > 0 {
+			log.Error(errs.ToAggregate(), "skipping namespace rule", "namespace", namespaceName)
+			recorder.Eventf(namespace, corev1.EventTypeWarning, "PolicyViolation",
+				"Mutations annotations not applied: %s", errs.ToAggregate())
+		} else {
+			rules = append(rules, *namespaceRule)
+		}
+	}
+
+	for _, rule := range selectPodRules(podRuleList.Items, pod, namespace) {
+		if excludedRules.Has(rule.Name) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// listValidationPodRules returns the enabled rules of the namespace matching the pod, sorted by applyOrder.
+// Opt-out and opt-in annotations only concern mutations, pods can't opt out of validations.
+func listValidationPodRules(ctx context.Context, c client.Client, pod *corev1.Pod, namespaceName string) ([]kuberule.PodRule, error) {
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace); err != nil {
+		return nil, err
+	}
+
+	podRuleList := &kuberule.PodRuleList{}
+	if err := c.List(ctx, client.InNamespace(namespaceName), podRuleList); err != nil {
+		return nil, err
+	}
+
+	return selectPodRules(podRuleList.Items, pod, namespace), nil
+}
+
+// selectPodRules returns the enabled rules selecting the pod and its namespace, sorted by applyOrder
+func selectPodRules(podRules []kuberule.PodRule, pod *corev1.Pod, namespace *corev1.Namespace) []kuberule.PodRule {
+	sort.Slice(podRules, func(i, j int) bool {
+		return podRules[i].Spec.ApplyOrder < podRules[j].Spec.ApplyOrder
+	})
+
+	rules := []kuberule.PodRule{}
+	for _, rule := range podRules {
+		// check matching pods, skip if doesn't match
+		podSelector, err := metav1.LabelSelectorAsSelector(&rule.Spec.Selector)
+		if err != nil {
+			log.Error(err, "skipping rule with invalid selector", "rule", rule.Name)
+			continue
+		}
+		if !podSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		// check the labels of the namespace, skip if they don't match
+		namespaceSelected, err := rule.Spec.SelectsNamespace(namespace)
+		if err != nil {
+			log.Error(err, "skipping rule with invalid namespace selector", "rule", rule.Name)
+			continue
+		}
+		if !namespaceSelected {
+			continue
+		}
+
+		if rule.Spec.Mode == kuberule.PodRuleModeDisabled {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// evaluatePodRule checks whether a matching rule currently applies on the pod.
+// When it doesn't, the evaluation result to be reported in metrics is returned.
+func evaluatePodRule(rule kuberule.PodRule, pod *corev1.Pod, now time.Time) (bool, string) {
+	// time-windowed rules, skip if not active
+	if active, reason, err := rule.Spec.ActiveAt(now); !active {
+		if err != nil {
+			log.Error(err, "unable to evaluate rule schedule", "rule", rule.Name)
+		}
+		log.Info("skipping inactive rule", "rule", rule.Name, "reason", reason)
+		return false, evaluationResultInactive
+	}
+
+	// gradual rollout, skip pods not included
+	if !inRollout(rule, pod) {
+		return false, evaluationResultSkipped
+	}
+
+	return true, ""
+}
+
+// skipPod checks whether the pod must not be mutated at all, returning the reason
+func skipPod(pod *corev1.Pod, namespace *corev1.Namespace) (bool, string) {
+	if pod.Annotations[kuberule.AnnotationSkip] == "true" {
+		return true, "pod annotated with " + kuberule.AnnotationSkip
+	}
+	if namespace.Annotations[kuberule.AnnotationSkip] == "true" {
+		return true, "namespace annotated with " + kuberule.AnnotationSkip
+	}
+	if config.PodsOptIn &&
+		pod.Annotations[kuberule.AnnotationEnabled] != "true" &&
+		namespace.Annotations[kuberule.AnnotationEnabled] != "true" {
+		return true, "opt-in mode and neither pod nor namespace annotated with " + kuberule.AnnotationEnabled
+	}
+
+	return false, ""
+}
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/config"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podRulesClient serves a namespace with its rules, profiles and the policies, other calls are not supported
+type podRulesClient struct {
+	client.Client
+	namespace       *corev1.Namespace
+	rules           []kuberule.PodRule
+	policies        []kuberule.PodRulePolicy
+	profiles        []kuberule.PodMutationProfile
+	clusterProfiles []kuberule.ClusterPodMutationProfile
+}
+
+func (c *podRulesClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	switch obj := obj.(type) {
+	case *corev1.Namespace:
+		c.namespace.DeepCopyInto(obj)
+		return nil
+	case *kuberule.PodMutationProfile:
+		for _, profile := range c.profiles {
+			if profile.Namespace == key.Namespace && profile.Name == key.Name {
+				profile.DeepCopyInto(obj)
+				return nil
+			}
+		}
+	case *kuberule.ClusterPodMutationProfile:
+		for _, profile := range c.clusterProfiles {
+			if profile.Name == key.Name {
+				profile.DeepCopyInto(obj)
+				return nil
+			}
+		}
+	}
+	return errors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+func (c *podRulesClient) List(_ context.Context, _ *client.ListOptions, list runtime.Object) error {
+	switch list := list.(type) {
+	case *kuberule.PodRuleList:
+		list.Items = append([]kuberule.PodRule{}, c.rules...)
+	case *kuberule.PodRulePolicyList:
+		list.Items = append([]kuberule.PodRulePolicy{}, c.policies...)
+	}
+	return nil
+}
+
+func TestSkipPod(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	annotated := func(annotations map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Annotations: annotations}
+	}
+
+	pod := &corev1.Pod{}
+	namespace := &corev1.Namespace{}
+	skip, _ := skipPod(pod, namespace)
+	g.Expect(skip).To(gomega.BeFalse())
+
+	skip, _ = skipPod(&corev1.Pod{ObjectMeta: annotated(map[string]string{kuberule.AnnotationSkip: "true"})}, namespace)
+	g.Expect(skip).To(gomega.BeTrue())
+
+	skip, _ = skipPod(pod, &corev1.Namespace{ObjectMeta: annotated(map[string]string{kuberule.AnnotationSkip: "true"})})
+	g.Expect(skip).To(gomega.BeTrue())
+
+	config.PodsOptIn = true
+	defer func() { config.PodsOptIn = false }()
+
+	skip, _ = skipPod(pod, namespace)
+	g.Expect(skip).To(gomega.BeTrue())
+
+	skip, _ = skipPod(pod, &corev1.Namespace{ObjectMeta: annotated(map[string]string{kuberule.AnnotationEnabled: "true"})})
+	g.Expect(skip).To(gomega.BeFalse())
+
+	skip, _ = skipPod(&corev1.Pod{ObjectMeta: annotated(map[string]string{
+		kuberule.AnnotationEnabled: "true",
+		kuberule.AnnotationSkip:    "true",
+	})}, namespace)
+	g.Expect(skip).To(gomega.BeTrue())
+}
+
+func TestSelectPodRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rule := func(name string, applyOrder int32, mode kuberule.PodRuleMode, namespaceSelector *metav1.LabelSelector) kuberule.PodRule {
+		return kuberule.PodRule{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: kuberule.PodRuleSpec{
+				ApplyOrder:        applyOrder,
+				Mode:              mode,
+				Selector:          metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				NamespaceSelector: namespaceSelector,
+			},
+		}
+	}
+	production := &metav1.LabelSelector{MatchLabels: map[string]string{"env": "production"}}
+	podRules := []kuberule.PodRule{
+		rule("production", 2, kuberule.PodRuleModeEnforce, production),
+		rule("all", 1, kuberule.PodRuleModeAudit, nil),
+		rule("disabled", 0, kuberule.PodRuleModeDisabled, nil),
+	}
+	names := func(rules []kuberule.PodRule) []string {
+		result := []string{}
+		for _, rule := range rules {
+			result = append(result, rule.Name)
+		}
+		return result
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}
+	staging := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "staging"}}}
+	g.Expect(names(selectPodRules(podRules, pod, staging))).To(gomega.Equal([]string{"all"}))
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "production"}}}
+	g.Expect(names(selectPodRules(podRules, pod, namespace))).To(gomega.Equal([]string{"all", "production"}))
+
+	g.Expect(selectPodRules(podRules, &corev1.Pod{}, namespace)).To(gomega.BeEmpty())
+}
+
+func TestListPodRulesNamespaceRule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	c := &podRulesClient{
+		namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "staging",
+			Annotations: map[string]string{
+				kuberule.AnnotationMutations: `{"labels": {"env": "staging"}, "nodeSelector": {"env": "staging"}}`,
+			},
+		}},
+	}
+	recorder := record.NewFakeRecorder(10)
+	pod := &corev1.Pod{}
+
+	rules, err := listPodRules(context.TODO(), c, recorder, pod, "staging")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rules).To(gomega.HaveLen(1))
+	g.Expect(rules[0].Name).To(gomega.Equal(kuberule.NamespaceRuleName))
+
+	// mutations denied by a policy drop the namespace rule
+	c.policies = []kuberule.PodRulePolicy{{
+		ObjectMeta: metav1.ObjectMeta{Name: "labels-only"},
+		Spec:       kuberule.PodRulePolicySpec{AllowedMutations: []string{"labels"}},
+	}}
+	rules, err = listPodRules(context.TODO(), c, recorder, pod, "staging")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(rules).To(gomega.BeEmpty())
+	g.Expect(recorder.Events).To(gomega.Receive(gomega.ContainSubstring("mutation nodeSelector is not allowed by podrulepolicy labels-only")))
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// serviceAccountTokenMountPath is where the ServiceAccount admission plugin mounts the token
+const serviceAccountTokenMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// reconcileServiceAccountToken fixes the token mounted by the ServiceAccount admission plugin, which runs
+// before the webhooks, once rules changed the service account of the pod or disabled the token automount.
+// Service accounts missing from the namespace are reverted, the pod would be rejected otherwise.
+func (a *podMutationHandler) reconcileServiceAccountToken(ctx context.Context, namespace string, pod, mutated *corev1.Pod) error {
+	if mutated.Spec.ServiceAccountName != pod.Spec.ServiceAccountName {
+		serviceAccount := &corev1.ServiceAccount{}
+		err := a.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: mutated.Spec.ServiceAccountName}, serviceAccount)
+		if errors.IsNotFound(err) {
+			log.Info("keeping service account of pod, rule service account not found",
+				"pod.name", pod.Name,
+				"pod.generateName", pod.GenerateName,
+				"serviceAccount", mutated.Spec.ServiceAccountName,
+			)
+			mutated.Spec.ServiceAccountName = pod.Spec.ServiceAccountName
+			mutated.Spec.DeprecatedServiceAccount = pod.Spec.DeprecatedServiceAccount
+		} else if err != nil {
+			return err
+		} else if err := a.retargetServiceAccountToken(ctx, namespace, mutated, serviceAccount); err != nil {
+			return err
+		}
+	}
+
+	if automount := mutated.Spec.AutomountServiceAccountToken; automount != nil && !*automount {
+		removeServiceAccountToken(mutated, serviceAccountTokenVolumes(mutated))
+	}
+
+	return nil
+}
+
+// retargetServiceAccountToken mounts the token secret of the new service account instead of the former one.
+// Projected tokens are always issued for the service account of the pod, they are kept as is.
+func (a *podMutationHandler) retargetServiceAccountToken(ctx context.Context, namespace string, pod *corev1.Pod, serviceAccount *corev1.ServiceAccount) error {
+	secretVolumes := sets.NewString()
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil && serviceAccountTokenVolumes(pod).Has(volume.Name) {
+			secretVolumes.Insert(volume.Name)
+		}
+	}
+	if secretVolumes.Len() == 0 {
+		return nil
+	}
+
+	tokenSecret := ""
+	for _, ref := range serviceAccount.Secrets {
+		secret := &corev1.Secret{}
+		err := a.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			tokenSecret = secret.Name
+			break
+		}
+	}
+
+	// never leave the token of the former service account
+	if tokenSecret == "" {
+		removeServiceAccountToken(pod, secretVolumes)
+		return nil
+	}
+
+	for i := range pod.Spec.Volumes {
+		if secretVolumes.Has(pod.Spec.Volumes[i].Name) {
+			pod.Spec.Volumes[i].Secret.SecretName = tokenSecret
+		}
+	}
+	return nil
+}
+
+// serviceAccountTokenVolumes returns the names of the volumes mounted at the service account token path
+func serviceAccountTokenVolumes(pod *corev1.Pod) sets.String {
+	names := sets.NewString()
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, mount := range container.VolumeMounts {
+				if mount.MountPath == serviceAccountTokenMountPath {
+					names.Insert(mount.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// removeServiceAccountToken removes the given token volumes and their mounts from the pod
+func removeServiceAccountToken(pod *corev1.Pod, names sets.String) {
+	if names.Len() == 0 {
+		return
+	}
+
+	removeMounts := func(containers []corev1.Container) {
+		for i := range containers {
+			mounts := []corev1.VolumeMount{}
+			for _, mount := range containers[i].VolumeMounts {
+				if !names.Has(mount.Name) {
+					mounts = append(mounts, mount)
+				}
+			}
+			if len(mounts) == 0 {
+				mounts = nil
+			}
+			containers[i].VolumeMounts = mounts
+		}
+	}
+	removeMounts(pod.Spec.InitContainers)
+	removeMounts(pod.Spec.Containers)
+
+	volumes := []corev1.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		if !names.Has(volume.Name) {
+			volumes = append(volumes, volume)
+		}
+	}
+	if len(volumes) == 0 {
+		volumes = nil
+	}
+	pod.Spec.Volumes = volumes
+}
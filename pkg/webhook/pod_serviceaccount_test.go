@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRemoveServiceAccountToken(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "data"},
+				{Name: "default-token-abcde", VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "default-token-abcde"},
+				}},
+			},
+			InitContainers: []corev1.Container{{
+				Name: "init",
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "default-token-abcde", MountPath: serviceAccountTokenMountPath},
+				},
+			}},
+			Containers: []corev1.Container{{
+				Name: "app",
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "data", MountPath: "/data"},
+					{Name: "default-token-abcde", MountPath: serviceAccountTokenMountPath},
+				},
+			}},
+		},
+	}
+
+	volumes := serviceAccountTokenVolumes(pod)
+	g.Expect(volumes.List()).To(gomega.Equal([]string{"default-token-abcde"}))
+
+	removeServiceAccountToken(pod, volumes)
+	g.Expect(pod.Spec.Volumes).To(gomega.Equal([]corev1.Volume{{Name: "data"}}))
+	g.Expect(pod.Spec.InitContainers[0].VolumeMounts).To(gomega.BeNil())
+	g.Expect(pod.Spec.Containers[0].VolumeMounts).To(gomega.Equal([]corev1.VolumeMount{{Name: "data", MountPath: "/data"}}))
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	admissiontypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+type podValidationHandler struct {
+	client   client.Client
+	decoder  admissiontypes.Decoder
+	recorder record.EventRecorder
+}
+
+var _ admission.Handler = &podValidationHandler{} // Implements admission.Handler.
+
+// podValidationHandler denies pods violating the validations of their rules
+func (a *podValidationHandler) Handle(ctx context.Context, req admissiontypes.Request) admissiontypes.Response {
+	pod := &corev1.Pod{}
+	err := a.decoder.Decode(req, pod)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+	operation := req.AdmissionRequest.Operation
+
+	log.Info("validating pod",
+		"request.namespace", req.AdmissionRequest.Namespace,
+		"request.operation", operation,
+		"pod.name", pod.Name,
+		"pod.generateName", pod.GenerateName,
+	)
+
+	// On UPDATE, only violations introduced by the update are denied,
+	// so existing pods can still be updated after a rule is created.
+	var oldPod *corev1.Pod
+	if operation == admissionv1beta1.Update {
+		oldPod = &corev1.Pod{}
+		if err := json.Unmarshal(req.AdmissionRequest.OldObject.Raw, oldPod); err != nil {
+			return admission.ErrorResponse(http.StatusBadRequest, err)
+		}
+	}
+
+	rules, err := listValidationPodRules(ctx, a.client, pod, req.AdmissionRequest.Namespace)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusInternalServerError, err)
+	}
+
+	// dry run requests don't record events nor metrics
+	dryRun := isDryRun(req)
+
+	denials := []string{}
+	now := time.Now()
+	for _, rule := range rules {
+		if rule.Spec.Validations == nil {
+			continue
+		}
+		// skipped and inactive rules are already reported by the mutating webhook
+		if applies, _ := evaluatePodRule(rule, pod, now); !applies {
+			continue
+		}
+
+		violations := validatePodFn(pod, *rule.Spec.Validations)
+		if oldPod != nil {
+			existing := sets.NewString(validatePodFn(oldPod, *rule.Spec.Validations)...)
+			introduced := []string{}
+			for _, violation := range violations {
+				if !existing.Has(violation) {
+					introduced = append(introduced, violation)
+				}
+			}
+			violations = introduced
+		}
+		if len(violations) == 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("rule %s: %s", rule.Name, strings.Join(violations, ", "))
+		if rule.Spec.Mode == kuberule.PodRuleModeAudit {
+			log.Info("auditing pod", "rule", rule.Name, "pod", podDisplayName(pod), "violations", violations)
+			if !dryRun {
+				podRuleEvaluations.WithLabelValues(rule.Namespace, rule.Name, evaluationResultAudited).Inc()
+				a.recorder.Eventf(&rule, corev1.EventTypeNormal, "Audited",
+					"%s pod %s would be denied: %s", operation, podDisplayName(pod), strings.Join(violations, ", "))
+			}
+			continue
+		}
+
+		if !dryRun {
+			podRuleEvaluations.WithLabelValues(rule.Namespace, rule.Name, evaluationResultDenied).Inc()
+		}
+		denials = append(denials, message)
+	}
+
+	if len(denials) > 0 {
+		return admission.ValidationResponse(false, "pod violates "+strings.Join(denials, "; "))
+	}
+
+	return admission.ValidationResponse(true, "OK")
+}
+
+// validatePodFn returns the violations of the given validations by the pod
+func validatePodFn(pod *corev1.Pod, validations kuberule.PodValidations) []string {
+	violations := []string{}
+
+	for _, key := range validations.RequiredLabels {
+		if _, ok := pod.Labels[key]; !ok {
+			violations = append(violations, fmt.Sprintf("missing label %s", key))
+		}
+	}
+
+	for _, key := range validations.RequiredAnnotations {
+		if _, ok := pod.Annotations[key]; !ok {
+			violations = append(violations, fmt.Sprintf("missing annotation %s", key))
+		}
+	}
+
+	for _, taint := range validations.MustNotTolerate {
+		for _, toleration := range pod.Spec.Tolerations {
+			if toleration.ToleratesTaint(&taint) {
+				violations = append(violations, fmt.Sprintf("must not tolerate taint %s", taint.ToString()))
+				break
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListValidationPodRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rule := func(name string, mode kuberule.PodRuleMode) kuberule.PodRule {
+		return kuberule.PodRule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: kuberule.PodRuleSpec{
+				Mode:        mode,
+				Selector:    metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Validations: &kuberule.PodValidations{RequiredLabels: []string{"team"}},
+			},
+		}
+	}
+	c := &podRulesClient{
+		namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		rules: []kuberule.PodRule{
+			rule("require-team", kuberule.PodRuleModeEnforce),
+			rule("disabled", kuberule.PodRuleModeDisabled),
+		},
+	}
+	ctx := context.TODO()
+
+	for _, annotations := range []map[string]string{
+		{kuberule.AnnotationSkip: "true"},
+		{kuberule.AnnotationExcludeRules: "require-team"},
+	} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"app": "web"},
+			Annotations: annotations,
+		}}
+
+		// the pod opted out of mutations
+		rules, err := listPodRules(ctx, c, nil, pod, "default")
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(rules).To(gomega.BeEmpty())
+
+		// but is still denied
+		rules, err = listValidationPodRules(ctx, c, pod, "default")
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(rules).To(gomega.HaveLen(1))
+		g.Expect(rules[0].Name).To(gomega.Equal("require-team"))
+		g.Expect(validatePodFn(pod, *rules[0].Spec.Validations)).To(gomega.Equal([]string{"missing label team"}))
+	}
+}
+
+func TestValidatePod(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	validations := kuberule.PodValidations{
+		RequiredLabels:      []string{"team"},
+		RequiredAnnotations: []string{"example.com/owner"},
+		MustNotTolerate: []corev1.Taint{{
+			Key:    "dedicated",
+			Value:  "gpu",
+			Effect: corev1.TaintEffectNoSchedule,
+		}},
+	}
+
+	pod := &corev1.Pod{}
+	pod.Labels = map[string]string{"team": "a"}
+	pod.Annotations = map[string]string{"example.com/owner": "someone"}
+	pod.Spec.Tolerations = []corev1.Toleration{{
+		Key:      "dedicated",
+		Operator: corev1.TolerationOpEqual,
+		Value:    "cpu",
+	}}
+	g.Expect(validatePodFn(pod, validations)).To(gomega.BeEmpty())
+
+	pod = &corev1.Pod{}
+	pod.Spec.Tolerations = []corev1.Toleration{{
+		Key:      "dedicated",
+		Operator: corev1.TolerationOpExists,
+	}}
+	g.Expect(validatePodFn(pod, validations)).To(gomega.Equal([]string{
+		"missing label team",
+		"missing annotation example.com/owner",
+		"must not tolerate taint dedicated=gpu:NoSchedule",
+	}))
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// addVolumes adds the allowed volumes missing from the pod, then mounts them in the selected containers.
+// Mounts can reference volumes of the rule or of the pod, mounts of missing volumes are skipped.
+func addVolumes(pod *corev1.Pod, mutations *kuberule.PodMutations) {
+	volumes := []corev1.Volume{}
+	for _, volume := range mutations.Volumes {
+		// the allowlist may have changed since the rule was validated
+		if violation := volumeViolation(&volume); violation != "" {
+			log.Info("skipping volume not allowed", "volume", volume.Name, "violation", violation)
+			continue
+		}
+		volumes = append(volumes, volume)
+	}
+	pod.Spec.Volumes = kuberule.MergeVolumes(pod.Spec.Volumes, volumes)
+
+	names := sets.NewString()
+	for _, volume := range pod.Spec.Volumes {
+		names.Insert(volume.Name)
+	}
+	mounts := []corev1.VolumeMount{}
+	for _, mount := range mutations.VolumeMounts {
+		if names.Has(mount.Name) {
+			mounts = append(mounts, mount)
+		}
+	}
+	if len(mounts) == 0 {
+		return
+	}
+
+	forEachSelectedContainer(pod, mutations.Containers, func(container *corev1.Container, _ kuberule.ContainerType) {
+		container.VolumeMounts = kuberule.MergeVolumeMounts(container.VolumeMounts, mounts)
+	})
+}
+
+// volumeViolation returns why the volume can't be added by rules, empty if it can
+func volumeViolation(volume *corev1.Volume) string {
+	sources := volumeSources(volume)
+	if len(sources) != 1 {
+		return "must specify exactly one volume source"
+	}
+
+	if !sets.NewString(config.PodsAllowedVolumeSources...).Has(sources[0]) {
+		return fmt.Sprintf("%s volumes are not allowed by the kube-rule configuration", sources[0])
+	}
+	if volume.HostPath != nil && !hostPathAllowed(volume.HostPath.Path) {
+		return fmt.Sprintf("host path %s is not allowed by the kube-rule configuration", volume.HostPath.Path)
+	}
+
+	return ""
+}
+
+// volumeSources returns the names of the source fields set on the volume, e.g. configMap
+func volumeSources(volume *corev1.Volume) []string {
+	// unset sources are omitted from JSON
+	raw, err := json.Marshal(volume.VolumeSource)
+	if err != nil {
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hostPathAllowed checks whether the path is under one of the allowed host path prefixes
+func hostPathAllowed(hostPath string) bool {
+	hostPath = path.Clean(hostPath)
+	for _, prefix := range config.PodsAllowedHostPathPrefixes {
+		prefix = path.Clean(prefix)
+		if hostPath == prefix || strings.HasPrefix(hostPath, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/config"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAddVolumes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	defer func(sources, prefixes []string) {
+		config.PodsAllowedVolumeSources = sources
+		config.PodsAllowedHostPathPrefixes = prefixes
+	}(config.PodsAllowedVolumeSources, config.PodsAllowedHostPathPrefixes)
+	config.PodsAllowedVolumeSources = []string{"configMap", "hostPath"}
+	config.PodsAllowedHostPathPrefixes = []string{"/var/run/node-local-dns"}
+
+	hostPath := func(name, path string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: path},
+		}}
+	}
+	caBundle := corev1.Volume{Name: "ca-bundle", VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "ca-bundle"}},
+	}}
+	dnsSocket := hostPath("dns-socket", "/var/run/node-local-dns/")
+	secret := corev1.Volume{Name: "creds", VolumeSource: corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{SecretName: "creds"},
+	}}
+
+	g.Expect(volumeViolation(&caBundle)).To(gomega.BeEmpty())
+	g.Expect(volumeViolation(&dnsSocket)).To(gomega.BeEmpty())
+	g.Expect(volumeViolation(&secret)).NotTo(gomega.BeEmpty())
+	g.Expect(volumeViolation(&corev1.Volume{Name: "empty"})).NotTo(gomega.BeEmpty())
+	docker := hostPath("docker", "/var/run/docker.sock")
+	g.Expect(volumeViolation(&docker)).NotTo(gomega.BeEmpty())
+	escape := hostPath("escape", "/var/run/node-local-dns/../docker.sock")
+	g.Expect(volumeViolation(&escape)).NotTo(gomega.BeEmpty())
+
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app"},
+				{Name: "istio-proxy"},
+			},
+		},
+	}
+	addVolumes(pod, &kuberule.PodMutations{
+		Volumes: []corev1.Volume{caBundle, secret},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "ca-bundle", MountPath: "/etc/ssl/certs"},
+			{Name: "creds", MountPath: "/etc/creds"},
+		},
+		Containers: &kuberule.ContainerSelector{ExcludeNames: []string{"istio-proxy"}},
+	})
+	g.Expect(pod.Spec.Volumes).To(gomega.Equal([]corev1.Volume{caBundle}))
+	g.Expect(pod.Spec.Containers[0].VolumeMounts).To(gomega.Equal([]corev1.VolumeMount{
+		{Name: "ca-bundle", MountPath: "/etc/ssl/certs"},
+	}))
+	g.Expect(pod.Spec.Containers[1].VolumeMounts).To(gomega.BeEmpty())
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+type podMutationProfileValidationHandler struct {
+	client  client.Client
+	decoder types.Decoder
+}
+
+// podMutationProfileValidationHandler Implements admission.Handler.
+var _ admission.Handler = &podMutationProfileValidationHandler{}
+
+// podMutationProfileValidationHandler handle namespaced mutation profiles validation
+func (a *podMutationProfileValidationHandler) Handle(ctx context.Context, req types.Request) types.Response {
+	profile := &kuberule.PodMutationProfile{}
+
+	err := a.decoder.Decode(req, profile)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	if profile.Namespace == "" {
+		profile.Namespace = req.AdmissionRequest.Namespace
+	}
+
+	log.Info("validating podmutationprofile",
+		"podmutationprofile", profile,
+		"request.namespace", req.AdmissionRequest.Namespace,
+		"request.operation", req.AdmissionRequest.Operation,
+	)
+
+	if errs := a.validateProfileFn(ctx, profile); len(errs) > 0 {
+		return admission.ValidationResponse(false, errs.ToAggregate().Error())
+	}
+
+	return admission.ValidationResponse(true, "OK")
+}
+
+// validateProfileFn validates the given profile, returning all errors found.
+// Namespaced profiles are restricted by the same policies as the PodRules referencing them.
+func (a *podMutationProfileValidationHandler) validateProfileFn(ctx context.Context, profile *kuberule.PodMutationProfile) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := validatePodMutations(&profile.Spec.Mutations, specPath.Child("mutations"))
+
+	policies, err := listPodRulePolicies(ctx, a.client, profile.Namespace)
+	if err != nil {
+		return append(allErrs, field.InternalError(specPath, err))
+	}
+
+	allErrs = append(allErrs, validatePodRulePolicies(policies, &profile.Spec.Mutations, specPath)...)
+
+	return append(allErrs, validateServiceAccountExists(ctx, a.client, profile.Namespace, &profile.Spec.Mutations, specPath.Child("mutations"))...)
+}
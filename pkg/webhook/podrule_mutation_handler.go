@@ -2,9 +2,14 @@ package webhook
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
-	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1alpha1"
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
@@ -18,7 +23,7 @@ type podRuleMutationHandler struct {
 // podRuleMutationHandler Implements admission.Handler.
 var _ admission.Handler = &podRuleMutationHandler{}
 
-// podRuleMutationHandler adds an annotation to every incoming pods.
+// podRuleMutationHandler sets defaults on incoming pod rules
 func (a *podRuleMutationHandler) Handle(ctx context.Context, req types.Request) types.Response {
 	// decode request
 	podRule := &kuberule.PodRule{}
@@ -43,7 +48,98 @@ func (a *podRuleMutationHandler) Handle(ctx context.Context, req types.Request)
 	return admission.PatchResponse(podRule, copy)
 }
 
-// mutatePodRuleFn add an annotation to the given pod rule
+// mutatePodRuleFn sets defaults on the given pod rule, so stored rules are explicit about their behavior
 func (a *podRuleMutationHandler) mutatePodRuleFn(ctx context.Context, podRule *kuberule.PodRule) error {
+	spec := &podRule.Spec
+
+	normalizeLabelSelector(&spec.Selector)
+	if spec.NamespaceSelector != nil {
+		normalizeLabelSelector(spec.NamespaceSelector)
+		// an empty selector selects all namespaces, same as no selector
+		if spec.NamespaceSelector.MatchLabels == nil && spec.NamespaceSelector.MatchExpressions == nil {
+			spec.NamespaceSelector = nil
+		}
+	}
+
+	if spec.Mode == "" {
+		spec.Mode = kuberule.PodRuleModeEnforce
+	}
+
+	for i := range spec.Profiles {
+		if spec.Profiles[i].Kind == "" {
+			spec.Profiles[i].Kind = kuberule.PodMutationProfileKindNamespaced
+		}
+	}
+
+	spec.Mutations.Strategies.Default()
+
+	for i := range spec.Mutations.Tolerations {
+		toleration := &spec.Mutations.Tolerations[i]
+		if toleration.Operator != "" {
+			continue
+		}
+		// an empty key only makes sense with Exists, tolerating everything
+		if toleration.Key == "" {
+			toleration.Operator = corev1.TolerationOpExists
+		} else {
+			toleration.Operator = corev1.TolerationOpEqual
+		}
+	}
+
+	// released by the controller on deletion, don't add it back while deleting
+	if podRule.DeletionTimestamp == nil {
+		found := false
+		for _, finalizer := range podRule.Finalizers {
+			if finalizer == kuberule.Finalizer {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			podRule.Finalizers = append(podRule.Finalizers, kuberule.Finalizer)
+		}
+	}
+
 	return nil
 }
+
+// normalizeLabelSelector moves single value In requirements to matchLabels,
+// sorts requirement values and removes duplicated requirements
+func normalizeLabelSelector(selector *metav1.LabelSelector) {
+	expressions := []metav1.LabelSelectorRequirement{}
+	seen := sets.NewString()
+	for _, requirement := range selector.MatchExpressions {
+		var values []string
+		if len(requirement.Values) > 0 {
+			values = sets.NewString(requirement.Values...).List()
+		}
+
+		if requirement.Operator == metav1.LabelSelectorOpIn && len(values) == 1 {
+			if existing, ok := selector.MatchLabels[requirement.Key]; !ok || existing == values[0] {
+				if selector.MatchLabels == nil {
+					selector.MatchLabels = map[string]string{}
+				}
+				selector.MatchLabels[requirement.Key] = values[0]
+				continue
+			}
+		}
+
+		id := fmt.Sprintf("%s %s %s", requirement.Key, requirement.Operator, strings.Join(values, ","))
+		if seen.Has(id) {
+			continue
+		}
+		seen.Insert(id)
+
+		requirement.Values = values
+		expressions = append(expressions, requirement)
+	}
+
+	if len(expressions) == 0 {
+		expressions = nil
+	}
+	selector.MatchExpressions = expressions
+	if len(selector.MatchLabels) == 0 {
+		selector.MatchLabels = nil
+	}
+}
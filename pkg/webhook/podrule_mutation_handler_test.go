@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMutatePodRule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	handler := &podRuleMutationHandler{}
+
+	podRule := &kuberule.PodRule{
+		Spec: kuberule.PodRuleSpec{
+			Selector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"app"}},
+					{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"staging", "dev", "dev"}},
+					{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev", "staging"}},
+				},
+			},
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"web"}},
+				},
+			},
+			Mutations: kuberule.PodMutations{
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Value: "app"},
+					{},
+				},
+			},
+		},
+	}
+	g.Expect(handler.mutatePodRuleFn(context.TODO(), podRule)).NotTo(gomega.HaveOccurred())
+
+	g.Expect(podRule.Spec.Selector).To(gomega.Equal(metav1.LabelSelector{
+		MatchLabels: map[string]string{"tier": "app"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev", "staging"}},
+		},
+	}))
+	g.Expect(podRule.Spec.NamespaceSelector).To(gomega.Equal(&metav1.LabelSelector{
+		MatchLabels: map[string]string{"team": "web"},
+	}))
+	g.Expect(podRule.Spec.Mode).To(gomega.Equal(kuberule.PodRuleModeEnforce))
+	g.Expect(podRule.Spec.Mutations.Strategies.NodeSelector).To(gomega.Equal(kuberule.MutationStrategyKeepExisting))
+	g.Expect(podRule.Spec.Mutations.Strategies.Tolerations).To(gomega.Equal(kuberule.MutationStrategyMerge))
+	g.Expect(podRule.Spec.Mutations.Tolerations[0].Operator).To(gomega.Equal(corev1.TolerationOpEqual))
+	g.Expect(podRule.Spec.Mutations.Tolerations[1].Operator).To(gomega.Equal(corev1.TolerationOpExists))
+	g.Expect(podRule.Finalizers).To(gomega.Equal([]string{kuberule.Finalizer}))
+
+	// defaulting is idempotent
+	defaulted := podRule.DeepCopy()
+	g.Expect(handler.mutatePodRuleFn(context.TODO(), defaulted)).NotTo(gomega.HaveOccurred())
+	g.Expect(defaulted).To(gomega.Equal(podRule))
+
+	// an empty namespace selector is dropped
+	podRule.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{}}
+	g.Expect(handler.mutatePodRuleFn(context.TODO(), podRule)).NotTo(gomega.HaveOccurred())
+	g.Expect(podRule.Spec.NamespaceSelector).To(gomega.BeNil())
+}
@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// listPodRulePolicies returns the policies selecting the given namespace
+func listPodRulePolicies(ctx context.Context, c client.Client, namespaceName string) ([]kuberule.PodRulePolicy, error) {
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace); err != nil {
+		return nil, err
+	}
+
+	policyList := &kuberule.PodRulePolicyList{}
+	if err := c.List(ctx, &client.ListOptions{}, policyList); err != nil {
+		return nil, err
+	}
+
+	policies := []kuberule.PodRulePolicy{}
+	for _, policy := range policyList.Items {
+		// no selector selects all namespaces
+		if policy.Spec.NamespaceSelector == nil {
+			policies = append(policies, policy)
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("podrulepolicy %s has invalid namespaceSelector: %s", policy.Name, err)
+		}
+		if selector.Matches(labels.Set(namespace.Labels)) {
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// usedMutations returns the field names of mutations set by the rule
+func usedMutations(mutations kuberule.PodMutations) ([]string, error) {
+	// unset mutations are omitted from JSON
+	raw, err := json.Marshal(mutations)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range fields {
+		// strategies and containers only tell how and where the other mutations are applied
+		if name == "strategies" || name == "containers" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// hasAnyPrefix checks whether the value starts with any of the prefixes
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAffinityKeys returns the node label keys used by the node affinity
+func nodeAffinityKeys(affinity *corev1.Affinity) []string {
+	if affinity == nil || affinity.NodeAffinity == nil {
+		return nil
+	}
+
+	terms := []corev1.NodeSelectorTerm{}
+	if required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+		terms = append(terms, required.NodeSelectorTerms...)
+	}
+	for _, preferred := range affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		terms = append(terms, preferred.Preference)
+	}
+
+	keys := []string{}
+	for _, term := range terms {
+		for _, requirement := range term.MatchExpressions {
+			keys = append(keys, requirement.Key)
+		}
+		for _, requirement := range term.MatchFields {
+			keys = append(keys, requirement.Key)
+		}
+	}
+	return keys
+}
+
+// podRulePolicyViolations returns what the mutations set that is not allowed by the policy
+func podRulePolicyViolations(policy kuberule.PodRulePolicy, mutations *kuberule.PodMutations) ([]string, error) {
+	violations := []string{}
+	spec := policy.Spec
+
+	if len(spec.AllowedMutations) > 0 {
+		used, err := usedMutations(*mutations)
+		if err != nil {
+			return nil, err
+		}
+		allowed := sets.NewString(spec.AllowedMutations...)
+		for _, name := range used {
+			if !allowed.Has(name) {
+				violations = append(violations, fmt.Sprintf("mutation %s is not allowed", name))
+			}
+		}
+	}
+
+	if len(spec.AllowedAnnotationPrefixes) > 0 {
+		for key := range mutations.Annotations {
+			if !hasAnyPrefix(key, spec.AllowedAnnotationPrefixes) {
+				violations = append(violations, fmt.Sprintf("annotation %s is not allowed", key))
+			}
+		}
+	}
+
+	if len(spec.AllowedLabelPrefixes) > 0 {
+		for key := range mutations.Labels {
+			if !hasAnyPrefix(key, spec.AllowedLabelPrefixes) {
+				violations = append(violations, fmt.Sprintf("label %s is not allowed", key))
+			}
+		}
+	}
+
+	if len(spec.AllowedTolerationKeys) > 0 {
+		allowed := sets.NewString(spec.AllowedTolerationKeys...)
+		for _, toleration := range mutations.Tolerations {
+			if !allowed.Has(toleration.Key) {
+				violations = append(violations, fmt.Sprintf("toleration key %q is not allowed", toleration.Key))
+			}
+		}
+	}
+
+	if len(spec.AllowedNodeSelectorKeys) > 0 {
+		allowed := sets.NewString(spec.AllowedNodeSelectorKeys...)
+		keys := nodeAffinityKeys(mutations.Affinity)
+		for key := range mutations.NodeSelector {
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			if !allowed.Has(key) {
+				violations = append(violations, fmt.Sprintf("node selector key %s is not allowed", key))
+			}
+		}
+	}
+
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// resolveAllowedMutations resolves the mutations of the rule and checks them against the policies of its namespace:
+// rules admitted before a policy existed or while the webhook was unavailable, and rules whose profiles changed since,
+// may not be allowed. When the rule can't be applied, nil mutations and the evaluation result are returned.
+func resolveAllowedMutations(ctx context.Context, c client.Client, recorder record.EventRecorder, rule *kuberule.PodRule, pod *corev1.Pod, policies []kuberule.PodRulePolicy) (*kuberule.PodMutations, string, error) {
+	// referenced profiles are applied before the inline mutations
+	mutations, err := podrules.ResolveMutations(ctx, c, rule)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, "", err
+		}
+		// reported by the controller in the rule status
+		log.Info("skipping rule with missing profile", "rule", rule.Name, "error", err.Error())
+		return nil, evaluationResultUnresolved, nil
+	}
+
+	if errs := validatePodRulePolicies(policies, mutations, field.NewPath("spec")); len(errs) > 0 {
+		log.Error(errs.ToAggregate(), "skipping rule not allowed by podrulepolicies", "rule", rule.Name)
+		recorder.Eventf(rule, corev1.EventTypeWarning, "PolicyViolation",
+			"Mutations not applied to pod %s: %s", podDisplayName(pod), errs.ToAggregate())
+		return nil, evaluationResultForbidden, nil
+	}
+
+	return mutations, "", nil
+}
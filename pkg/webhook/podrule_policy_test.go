@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPodRulePolicyViolations(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	policy := kuberule.PodRulePolicy{
+		Spec: kuberule.PodRulePolicySpec{
+			AllowedMutations:          []string{"annotations", "affinity", "nodeSelector", "tolerations"},
+			AllowedAnnotationPrefixes: []string{"example.com/"},
+			AllowedTolerationKeys:     []string{"dedicated-env"},
+			AllowedNodeSelectorKeys:   []string{"example.com/env"},
+		},
+	}
+
+	allowed := &kuberule.PodRule{
+		Spec: kuberule.PodRuleSpec{
+			Mutations: kuberule.PodMutations{
+				Annotations:  map[string]string{"example.com/log": "true"},
+				NodeSelector: map[string]string{"example.com/env": "staging"},
+				Tolerations:  []corev1.Toleration{{Key: "dedicated-env", Value: "staging"}},
+			},
+		},
+	}
+	violations, err := podRulePolicyViolations(policy, &allowed.Spec.Mutations)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(violations).To(gomega.BeEmpty())
+
+	denied := &kuberule.PodRule{
+		Spec: kuberule.PodRuleSpec{
+			Mutations: kuberule.PodMutations{
+				Annotations: map[string]string{"other.com/log": "true"},
+				Labels:      map[string]string{"tier": "app"},
+				Tolerations: []corev1.Toleration{{Key: "dedicated", Value: "gpu"}},
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+								MatchExpressions: []corev1.NodeSelectorRequirement{{
+									Key:      "gpu",
+									Operator: corev1.NodeSelectorOpExists,
+								}},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+	violations, err = podRulePolicyViolations(policy, &denied.Spec.Mutations)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(violations).To(gomega.Equal([]string{
+		"annotation other.com/log is not allowed",
+		"mutation labels is not allowed",
+		"node selector key gpu is not allowed",
+		"toleration key \"dedicated\" is not allowed",
+	}))
+}
+
+func TestPodRulePoliciesProfiles(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	c := &podRulesClient{
+		namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+		policies: []kuberule.PodRulePolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+			Spec:       kuberule.PodRulePolicySpec{AllowedMutations: []string{"labels"}},
+		}},
+		clusterProfiles: []kuberule.ClusterPodMutationProfile{{
+			ObjectMeta: metav1.ObjectMeta{Name: "dedicated"},
+			Spec: kuberule.PodMutationProfileSpec{Mutations: kuberule.PodMutations{
+				Tolerations: []corev1.Toleration{{Key: "dedicated", Value: "gpu"}},
+			}},
+		}},
+	}
+	rule := &kuberule.PodRule{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "gpu"},
+		Spec: kuberule.PodRuleSpec{
+			MatchAll: true,
+			Profiles: []kuberule.PodMutationProfileReference{
+				{Kind: kuberule.PodMutationProfileKindCluster, Name: "dedicated"},
+				{Kind: kuberule.PodMutationProfileKindNamespaced, Name: "missing"},
+			},
+			Mutations: kuberule.PodMutations{Labels: map[string]string{"team": "ml"}},
+		},
+	}
+
+	// rules can't be admitted with profiles setting what they aren't allowed to
+	handler := &podRuleValidationHandler{client: c}
+	errs := handler.validatePodRuleFn(context.TODO(), rule)
+	g.Expect(errs.ToAggregate().Error()).To(gomega.ContainSubstring("mutation tolerations is not allowed by podrulepolicy tenants"))
+
+	// and aren't applied on pods once the missing profile exists
+	c.profiles = []kuberule.PodMutationProfile{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "missing"},
+	}}
+	recorder := record.NewFakeRecorder(10)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "trainer"}}
+	mutations, result, err := resolveAllowedMutations(context.TODO(), c, recorder, rule, pod, c.policies)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(mutations).To(gomega.BeNil())
+	g.Expect(result).To(gomega.Equal(evaluationResultForbidden))
+	g.Expect(recorder.Events).To(gomega.Receive(gomega.ContainSubstring("Mutations not applied to pod trainer")))
+
+	c.clusterProfiles = nil
+	rule.Spec.Profiles = rule.Spec.Profiles[1:]
+	mutations, _, err = resolveAllowedMutations(context.TODO(), c, recorder, rule, pod, c.policies)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(mutations.Labels).To(gomega.Equal(map[string]string{"team": "ml"}))
+	g.Expect(handler.validatePodRuleFn(context.TODO(), rule)).To(gomega.BeEmpty())
+}
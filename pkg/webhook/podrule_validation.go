@@ -0,0 +1,586 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	supportedTolerationOperators = sets.NewString(
+		string(corev1.TolerationOpEqual),
+		string(corev1.TolerationOpExists),
+	)
+	supportedTaintEffects = sets.NewString(
+		string(corev1.TaintEffectNoSchedule),
+		string(corev1.TaintEffectPreferNoSchedule),
+		string(corev1.TaintEffectNoExecute),
+	)
+	supportedNodeSelectorOperators = sets.NewString(
+		string(corev1.NodeSelectorOpIn),
+		string(corev1.NodeSelectorOpNotIn),
+		string(corev1.NodeSelectorOpExists),
+		string(corev1.NodeSelectorOpDoesNotExist),
+		string(corev1.NodeSelectorOpGt),
+		string(corev1.NodeSelectorOpLt),
+	)
+	supportedStrategies = sets.NewString(
+		string(kuberule.MutationStrategyKeepExisting),
+		string(kuberule.MutationStrategyMerge),
+		string(kuberule.MutationStrategyOverride),
+	)
+	supportedModes = sets.NewString(
+		string(kuberule.PodRuleModeEnforce),
+		string(kuberule.PodRuleModeAudit),
+		string(kuberule.PodRuleModeDisabled),
+	)
+	supportedDNSPolicies = sets.NewString(
+		string(corev1.DNSClusterFirstWithHostNet),
+		string(corev1.DNSClusterFirst),
+		string(corev1.DNSDefault),
+		string(corev1.DNSNone),
+	)
+	supportedPullPolicies = sets.NewString(
+		string(corev1.PullAlways),
+		string(corev1.PullIfNotPresent),
+		string(corev1.PullNever),
+	)
+	supportedContainerTypes = sets.NewString(
+		string(kuberule.ContainerTypeInit),
+		string(kuberule.ContainerTypeRegular),
+	)
+	supportedProfileKinds = sets.NewString(
+		string(kuberule.PodMutationProfileKindNamespaced),
+		string(kuberule.PodMutationProfileKindCluster),
+	)
+)
+
+// validatePodRuleSpec validates the pod rule spec, except for cluster policies
+func validatePodRuleSpec(spec *kuberule.PodRuleSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.ApplyOrder < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("applyOrder"), spec.ApplyOrder, "must be >= 0"))
+	}
+
+	allErrs = append(allErrs, metav1validation.ValidateLabelSelector(&spec.Selector, fldPath.Child("selector"))...)
+	if len(spec.Selector.MatchLabels) == 0 && len(spec.Selector.MatchExpressions) == 0 && !spec.MatchAll {
+		allErrs = append(allErrs, field.Required(fldPath.Child("matchAll"),
+			"must be true to use an empty selector, selecting all pods of the namespace"))
+	}
+	if spec.NamespaceSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(spec.NamespaceSelector, fldPath.Child("namespaceSelector"))...)
+	}
+
+	if spec.Mode != "" && !supportedModes.Has(string(spec.Mode)) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("mode"), spec.Mode, supportedModes.List()))
+	}
+
+	for i, profile := range spec.Profiles {
+		allErrs = append(allErrs, validatePodMutationProfileReference(&profile, fldPath.Child("profiles").Index(i))...)
+	}
+
+	allErrs = append(allErrs, validatePodMutations(&spec.Mutations, fldPath.Child("mutations"))...)
+
+	for i, source := range spec.ImagePullSecretSources {
+		idxPath := fldPath.Child("imagePullSecretSources").Index(i)
+		for _, msg := range validation.IsDNS1123Label(source.Namespace) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("namespace"), source.Namespace, msg))
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(source.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), source.Name, msg))
+		}
+	}
+
+	if spec.Validations != nil {
+		allErrs = append(allErrs, validatePodValidations(spec.Validations, fldPath.Child("validations"))...)
+	}
+
+	if spec.Rollout != nil && (spec.Rollout.Percentage < 0 || spec.Rollout.Percentage > 100) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rollout", "percentage"), spec.Rollout.Percentage, "must be between 0 and 100"))
+	}
+
+	if spec.ActiveFrom != nil && spec.ActiveUntil != nil && !spec.ActiveFrom.Before(spec.ActiveUntil) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("activeUntil"), spec.ActiveUntil, "must be after activeFrom"))
+	}
+
+	if schedule := spec.Schedule; schedule != nil {
+		if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("schedule", "cron"), schedule.Cron, err.Error()))
+		}
+		if schedule.Duration.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("schedule", "duration"), schedule.Duration.String(), "must be > 0"))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePodMutationProfileReference checks the kind and name of the referenced profile
+func validatePodMutationProfileReference(ref *kuberule.PodMutationProfileReference, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if ref.Kind != "" && !supportedProfileKinds.Has(string(ref.Kind)) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("kind"), ref.Kind, supportedProfileKinds.List()))
+	}
+
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), ref.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePodMutations validates the mutations are valid pod fields
+func validatePodMutations(mutations *kuberule.PodMutations, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, apimachineryvalidation.ValidateAnnotations(mutations.Annotations, fldPath.Child("annotations"))...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(mutations.Labels, fldPath.Child("labels"))...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(mutations.NodeSelector, fldPath.Child("nodeSelector"))...)
+
+	if mutations.Affinity != nil {
+		allErrs = append(allErrs, validateAffinity(mutations.Affinity, fldPath.Child("affinity"))...)
+	}
+
+	for i, secret := range mutations.ImagePullSecrets {
+		idxPath := fldPath.Child("imagePullSecrets").Index(i).Child("name")
+		for _, msg := range validation.IsDNS1123Subdomain(secret.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath, secret.Name, msg))
+		}
+	}
+
+	for i, toleration := range mutations.Tolerations {
+		allErrs = append(allErrs, validateToleration(&toleration, fldPath.Child("tolerations").Index(i))...)
+	}
+
+	if mutations.ServiceAccountName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(mutations.ServiceAccountName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceAccountName"), mutations.ServiceAccountName, msg))
+		}
+	}
+
+	if mutations.DNSPolicy != "" && !supportedDNSPolicies.Has(string(mutations.DNSPolicy)) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("dnsPolicy"), mutations.DNSPolicy, supportedDNSPolicies.List()))
+	}
+	if mutations.DNSPolicy == corev1.DNSNone && (mutations.DNSConfig == nil || len(mutations.DNSConfig.Nameservers) == 0) {
+		allErrs = append(allErrs, field.Required(fldPath.Child("dnsConfig", "nameservers"), "must be set when dnsPolicy is None"))
+	}
+	if mutations.DNSConfig != nil {
+		allErrs = append(allErrs, validatePodDNSConfig(mutations.DNSConfig, fldPath.Child("dnsConfig"))...)
+	}
+
+	for i, alias := range mutations.HostAliases {
+		idxPath := fldPath.Child("hostAliases").Index(i)
+		if net.ParseIP(alias.IP) == nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("ip"), alias.IP, "must be a valid IP address"))
+		}
+		for j, hostname := range alias.Hostnames {
+			for _, msg := range validation.IsDNS1123Subdomain(hostname) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("hostnames").Index(j), hostname, msg))
+			}
+		}
+	}
+
+	if period := mutations.TerminationGracePeriodSeconds; period != nil && *period < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("terminationGracePeriodSeconds"), *period, "must be greater than or equal to 0"))
+	}
+	if mutations.PreStop != nil {
+		allErrs = append(allErrs, validateContainerPreStop(mutations.PreStop, fldPath.Child("preStop"))...)
+	}
+	for i, gate := range mutations.ReadinessGates {
+		for _, msg := range validation.IsQualifiedName(string(gate.ConditionType)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("readinessGates").Index(i).Child("conditionType"), gate.ConditionType, msg))
+		}
+	}
+
+	for i, rewrite := range mutations.ImageRegistryRewrites {
+		allErrs = append(allErrs, validateImageRegistryRewrite(&rewrite, fldPath.Child("imageRegistryRewrites").Index(i))...)
+	}
+	if mutations.ImagePullPolicy != "" && !supportedPullPolicies.Has(string(mutations.ImagePullPolicy)) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("imagePullPolicy"), mutations.ImagePullPolicy, supportedPullPolicies.List()))
+	}
+
+	for i, volume := range mutations.Volumes {
+		idxPath := fldPath.Child("volumes").Index(i)
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), volume.Name, msg))
+		}
+		if violation := volumeViolation(&volume); violation != "" {
+			allErrs = append(allErrs, field.Forbidden(idxPath, violation))
+		}
+	}
+	for i, mount := range mutations.VolumeMounts {
+		idxPath := fldPath.Child("volumeMounts").Index(i)
+		if mount.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		}
+		if !path.IsAbs(mount.MountPath) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("mountPath"), mount.MountPath, "must be an absolute path"))
+		}
+	}
+
+	if mutations.Containers != nil {
+		allErrs = append(allErrs, validateContainerSelector(mutations.Containers, fldPath.Child("containers"))...)
+	}
+
+	allErrs = append(allErrs, validatePodMutationStrategies(&mutations.Strategies, fldPath.Child("strategies"))...)
+
+	return allErrs
+}
+
+// validatePodDNSConfig checks nameservers are IP addresses, searches are domains and options are named
+func validatePodDNSConfig(dnsConfig *corev1.PodDNSConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, nameserver := range dnsConfig.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nameservers").Index(i), nameserver, "must be a valid IP address"))
+		}
+	}
+
+	for i, search := range dnsConfig.Searches {
+		// searches may be fully qualified
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimSuffix(search, ".")) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("searches").Index(i), search, msg))
+		}
+	}
+
+	for i, option := range dnsConfig.Options {
+		if option.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("options").Index(i).Child("name"), ""))
+		}
+	}
+
+	return allErrs
+}
+
+// validateContainerPreStop checks the targeted container names and that the handler has exactly one action
+func validateContainerPreStop(preStop *kuberule.ContainerPreStop, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, name := range preStop.Containers {
+		for _, msg := range validation.IsDNS1123Label(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("containers").Index(i), name, msg))
+		}
+	}
+
+	handlerPath := fldPath.Child("handler")
+	actions := 0
+	if preStop.Handler.Exec != nil {
+		actions++
+		if len(preStop.Handler.Exec.Command) == 0 {
+			allErrs = append(allErrs, field.Required(handlerPath.Child("exec", "command"), ""))
+		}
+	}
+	if preStop.Handler.HTTPGet != nil {
+		actions++
+	}
+	if preStop.Handler.TCPSocket != nil {
+		actions++
+	}
+	if actions != 1 {
+		allErrs = append(allErrs, field.Invalid(handlerPath, preStop.Handler, "must specify exactly one of exec, httpGet or tcpSocket"))
+	}
+
+	return allErrs
+}
+
+// validateContainerSelector checks the patterns are valid globs and the container types are supported
+func validateContainerSelector(selector *kuberule.ContainerSelector, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	patternsByName := map[string][]string{
+		"names":        selector.Names,
+		"excludeNames": selector.ExcludeNames,
+		"images":       selector.Images,
+	}
+	for _, name := range []string{"excludeNames", "images", "names"} {
+		for i, pattern := range patternsByName[name] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(name).Index(i), pattern, err.Error()))
+			}
+		}
+	}
+
+	for i, containerType := range selector.Types {
+		if !supportedContainerTypes.Has(string(containerType)) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("types").Index(i), containerType, supportedContainerTypes.List()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateImageRegistryRewrite checks the rewritten images have a registry and can't be rewritten again
+func validateImageRegistryRewrite(rewrite *kuberule.ImageRegistryRewrite, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if rewrite.From == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("from"), ""))
+	}
+	if rewrite.To == "" {
+		return append(allErrs, field.Required(fldPath.Child("to"), ""))
+	}
+
+	// images without registry would be considered from docker.io
+	if !isRegistryHost(strings.SplitN(rewrite.To, "/", 2)[0]) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("to"), rewrite.To, "must start with a registry host, e.g. registry.internal/"))
+	} else if rewrite.From != "" && strings.HasPrefix(rewrite.To, rewrite.From) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("to"), rewrite.To, "must not start with from, images would be rewritten again"))
+	}
+
+	return allErrs
+}
+
+// validateToleration checks the operator and effect combination of the toleration
+func validateToleration(toleration *corev1.Toleration, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if toleration.Key != "" {
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(toleration.Key, fldPath.Child("key"))...)
+	}
+
+	// an empty key tolerates everything, only possible with Exists
+	if toleration.Key == "" && toleration.Operator != corev1.TolerationOpExists {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("operator"), toleration.Operator,
+			"operator must be Exists when `key` is empty, which means \"match all values and all keys\""))
+	}
+
+	if toleration.TolerationSeconds != nil && toleration.Effect != corev1.TaintEffectNoExecute {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("effect"), toleration.Effect,
+			"effect must be 'NoExecute' when `tolerationSeconds` is set"))
+	}
+
+	switch toleration.Operator {
+	case corev1.TolerationOpEqual, "":
+		for _, msg := range validation.IsValidLabelValue(toleration.Value) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("value"), toleration.Value, msg))
+		}
+	case corev1.TolerationOpExists:
+		if toleration.Value != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("value"), toleration.Value,
+				"value must be empty when `operator` is 'Exists'"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("operator"), toleration.Operator, supportedTolerationOperators.List()))
+	}
+
+	if toleration.Effect != "" && !supportedTaintEffects.Has(string(toleration.Effect)) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("effect"), toleration.Effect, supportedTaintEffects.List()))
+	}
+
+	return allErrs
+}
+
+// validateAffinity checks the structure of node, pod and pod anti affinities
+func validateAffinity(affinity *corev1.Affinity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if nodeAffinity := affinity.NodeAffinity; nodeAffinity != nil {
+		nodePath := fldPath.Child("nodeAffinity")
+		if required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			requiredPath := nodePath.Child("requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+			if len(required.NodeSelectorTerms) == 0 {
+				allErrs = append(allErrs, field.Required(requiredPath, "must have at least one node selector term"))
+			}
+			for i, term := range required.NodeSelectorTerms {
+				allErrs = append(allErrs, validateNodeSelectorTerm(&term, requiredPath.Index(i))...)
+			}
+		}
+		for i, preferred := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			preferredPath := nodePath.Child("preferredDuringSchedulingIgnoredDuringExecution").Index(i)
+			allErrs = append(allErrs, validateWeight(preferred.Weight, preferredPath.Child("weight"))...)
+			allErrs = append(allErrs, validateNodeSelectorTerm(&preferred.Preference, preferredPath.Child("preference"))...)
+		}
+	}
+
+	if podAffinity := affinity.PodAffinity; podAffinity != nil {
+		allErrs = append(allErrs, validatePodAffinityTerms(
+			podAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			podAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			fldPath.Child("podAffinity"))...)
+	}
+
+	if podAntiAffinity := affinity.PodAntiAffinity; podAntiAffinity != nil {
+		allErrs = append(allErrs, validatePodAffinityTerms(
+			podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			fldPath.Child("podAntiAffinity"))...)
+	}
+
+	return allErrs
+}
+
+func validateNodeSelectorTerm(term *corev1.NodeSelectorTerm, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, requirement := range term.MatchExpressions {
+		idxPath := fldPath.Child("matchExpressions").Index(i)
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(requirement.Key, idxPath.Child("key"))...)
+		allErrs = append(allErrs, validateNodeSelectorRequirement(&requirement, idxPath)...)
+	}
+	for i, requirement := range term.MatchFields {
+		allErrs = append(allErrs, validateNodeSelectorRequirement(&requirement, fldPath.Child("matchFields").Index(i))...)
+	}
+
+	return allErrs
+}
+
+func validateNodeSelectorRequirement(requirement *corev1.NodeSelectorRequirement, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+		if len(requirement.Values) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("values"), "must be specified when `operator` is 'In' or 'NotIn'"))
+		}
+	case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+		if len(requirement.Values) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("values"), "may not be specified when `operator` is 'Exists' or 'DoesNotExist'"))
+		}
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if len(requirement.Values) != 1 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("values"), "must be specified single value when `operator` is 'Lt' or 'Gt'"))
+		} else if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("values").Index(0), requirement.Values[0], "must be an integer when `operator` is 'Lt' or 'Gt'"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("operator"), requirement.Operator, supportedNodeSelectorOperators.List()))
+	}
+
+	return allErrs
+}
+
+func validatePodAffinityTerms(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, term := range required {
+		allErrs = append(allErrs, validatePodAffinityTerm(&term, fldPath.Child("requiredDuringSchedulingIgnoredDuringExecution").Index(i))...)
+	}
+	for i, weighted := range preferred {
+		idxPath := fldPath.Child("preferredDuringSchedulingIgnoredDuringExecution").Index(i)
+		allErrs = append(allErrs, validateWeight(weighted.Weight, idxPath.Child("weight"))...)
+		allErrs = append(allErrs, validatePodAffinityTerm(&weighted.PodAffinityTerm, idxPath.Child("podAffinityTerm"))...)
+	}
+
+	return allErrs
+}
+
+func validatePodAffinityTerm(term *corev1.PodAffinityTerm, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if term.LabelSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(term.LabelSelector, fldPath.Child("labelSelector"))...)
+	}
+	for i, name := range term.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("namespaces").Index(i), name, msg))
+		}
+	}
+	if term.TopologyKey == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("topologyKey"), "can not be empty"))
+	} else {
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(term.TopologyKey, fldPath.Child("topologyKey"))...)
+	}
+
+	return allErrs
+}
+
+func validateWeight(weight int32, fldPath *field.Path) field.ErrorList {
+	if weight < 1 || weight > 100 {
+		return field.ErrorList{field.Invalid(fldPath, weight, "must be in the range 1-100")}
+	}
+	return nil
+}
+
+// validatePodMutationStrategies checks the strategies are supported by their mutation
+func validatePodMutationStrategies(strategies *kuberule.PodMutationStrategies, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	// strategies are checked in the order of their sorted names, for the errors to be deterministic
+	strategiesByName := map[string]kuberule.MutationStrategy{
+		"annotations":                   strategies.Annotations,
+		"labels":                        strategies.Labels,
+		"affinity":                      strategies.Affinity,
+		"nodeSelector":                  strategies.NodeSelector,
+		"imagePullSecrets":              strategies.ImagePullSecrets,
+		"tolerations":                   strategies.Tolerations,
+		"serviceAccountName":            strategies.ServiceAccountName,
+		"automountServiceAccountToken":  strategies.AutomountServiceAccountToken,
+		"dnsPolicy":                     strategies.DNSPolicy,
+		"dnsConfig":                     strategies.DNSConfig,
+		"hostAliases":                   strategies.HostAliases,
+		"terminationGracePeriodSeconds": strategies.TerminationGracePeriodSeconds,
+		"readinessGates":                strategies.ReadinessGates,
+		"imagePullPolicy":               strategies.ImagePullPolicy,
+	}
+	for _, name := range sets.StringKeySet(strategiesByName).List() {
+		if strategy := strategiesByName[name]; strategy != "" && !supportedStrategies.Has(string(strategy)) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child(name), strategy, supportedStrategies.List()))
+		}
+	}
+	for _, name := range []string{"affinity", "automountServiceAccountToken", "dnsPolicy", "imagePullPolicy", "serviceAccountName"} {
+		if strategy := strategiesByName[name]; strategy == kuberule.MutationStrategyMerge {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(name), strategy, name+" can't be merged"))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePodValidations checks the validations constraints are well formed
+func validatePodValidations(validations *kuberule.PodValidations, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, key := range validations.RequiredLabels {
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(key, fldPath.Child("requiredLabels").Index(i))...)
+	}
+	for i, key := range validations.RequiredAnnotations {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requiredAnnotations").Index(i), key, msg))
+		}
+	}
+	for i, taint := range validations.MustNotTolerate {
+		idxPath := fldPath.Child("mustNotTolerate").Index(i)
+		allErrs = append(allErrs, metav1validation.ValidateLabelName(taint.Key, idxPath.Child("key"))...)
+		if !supportedTaintEffects.Has(string(taint.Effect)) {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("effect"), taint.Effect, supportedTaintEffects.List()))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePodRulePolicies denies the mutations the policies don't allow to set
+func validatePodRulePolicies(policies []kuberule.PodRulePolicy, mutations *kuberule.PodMutations, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for _, policy := range policies {
+		violations, err := podRulePolicyViolations(policy, mutations)
+		if err != nil {
+			allErrs = append(allErrs, field.InternalError(fldPath, err))
+			continue
+		}
+		for _, violation := range violations {
+			allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("%s by podrulepolicy %s", violation, policy.Name)))
+		}
+	}
+
+	return allErrs
+}
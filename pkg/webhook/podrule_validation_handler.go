@@ -2,25 +2,29 @@ package webhook
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 
-	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1alpha1"
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/chickenzord/kube-rule/pkg/podrules"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
-	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+	admissiontypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
 )
 
 type podRuleValidationHandler struct {
 	client  client.Client
-	decoder types.Decoder
+	decoder admissiontypes.Decoder
 }
 
 // podRuleValidationHandler Implements admission.Handler.
 var _ admission.Handler = &podRuleValidationHandler{}
 
 // podRuleValidationHandler handle pod rules validation
-func (a *podRuleValidationHandler) Handle(ctx context.Context, req types.Request) types.Response {
+func (a *podRuleValidationHandler) Handle(ctx context.Context, req admissiontypes.Request) admissiontypes.Response {
 	podRule := &kuberule.PodRule{}
 
 	err := a.decoder.Decode(req, podRule)
@@ -28,24 +32,59 @@ func (a *podRuleValidationHandler) Handle(ctx context.Context, req types.Request
 		return admission.ErrorResponse(http.StatusBadRequest, err)
 	}
 
+	if podRule.Namespace == "" {
+		podRule.Namespace = req.AdmissionRequest.Namespace
+	}
+
 	log.Info("validating podrule",
 		"podrule", podRule,
 		"request.namespace", req.AdmissionRequest.Namespace,
 		"request.operation", req.AdmissionRequest.Operation,
 	)
 
-	if err := a.validatePodRuleFn(ctx, podRule); err != nil {
-		return admission.ValidationResponse(false, err.Error())
+	if errs := a.validatePodRuleFn(ctx, podRule); len(errs) > 0 {
+		return admission.ValidationResponse(false, errs.ToAggregate().Error())
 	}
 
 	return admission.ValidationResponse(true, "OK")
 }
 
-// validatePodRuleFn validates the given pod rule
-func (a *podRuleValidationHandler) validatePodRuleFn(ctx context.Context, podRule *kuberule.PodRule) error {
-	if podRule.Spec.ApplyOrder < 0 {
-		return fmt.Errorf("podrule.spec.applyOrder must be >= 0")
+// validatePodRuleFn validates the given pod rule, returning all errors found
+func (a *podRuleValidationHandler) validatePodRuleFn(ctx context.Context, podRule *kuberule.PodRule) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := validatePodRuleSpec(&podRule.Spec, specPath)
+
+	// enforce restrictions set by cluster admins on the namespace
+	policies, err := listPodRulePolicies(ctx, a.client, podRule.Namespace)
+	if err != nil {
+		return append(allErrs, field.InternalError(specPath, err))
+	}
+
+	// referenced profiles can't set what the rule isn't allowed to, missing ones being checked once they exist
+	mutations, err := podrules.ResolveExistingMutations(ctx, a.client, podRule)
+	if err != nil {
+		return append(allErrs, field.InternalError(specPath.Child("profiles"), err))
 	}
 
+	allErrs = append(allErrs, validatePodRulePolicies(policies, mutations, specPath)...)
+
+	return append(allErrs, validateServiceAccountExists(ctx, a.client, podRule.Namespace, &podRule.Spec.Mutations, specPath.Child("mutations"))...)
+}
+
+// validateServiceAccountExists checks the service account set by the mutations exists in the namespace
+func validateServiceAccountExists(ctx context.Context, c client.Client, namespace string, mutations *kuberule.PodMutations, fldPath *field.Path) field.ErrorList {
+	if mutations.ServiceAccountName == "" {
+		return nil
+	}
+
+	fldPath = fldPath.Child("serviceAccountName")
+	serviceAccount := &corev1.ServiceAccount{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: mutations.ServiceAccountName}, serviceAccount)
+	if errors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(fldPath, mutations.ServiceAccountName)}
+	}
+	if err != nil {
+		return field.ErrorList{field.InternalError(fldPath, err)}
+	}
 	return nil
 }
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"testing"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidatePodRuleSpec(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	fields := func(errs field.ErrorList) []string {
+		names := []string{}
+		for _, err := range errs {
+			names = append(names, err.Field)
+		}
+		return names
+	}
+
+	valid := &kuberule.PodRuleSpec{
+		Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "app"}},
+		Profiles: []kuberule.PodMutationProfileReference{
+			{Name: "base"},
+			{Kind: kuberule.PodMutationProfileKindCluster, Name: "dedicated-app"},
+		},
+		Mutations: kuberule.PodMutations{
+			Annotations:      map[string]string{"example.com/log": "true"},
+			NodeSelector:     map[string]string{"kubernetes.io/role": "app"},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "dockerhub-creds"}},
+			Tolerations: []corev1.Toleration{
+				{Key: "dedicated-env", Operator: corev1.TolerationOpEqual, Value: "production"},
+				{Operator: corev1.TolerationOpExists},
+			},
+		},
+	}
+	g.Expect(validatePodRuleSpec(valid, field.NewPath("spec"))).To(gomega.BeEmpty())
+
+	matchAll := &kuberule.PodRuleSpec{MatchAll: true}
+	g.Expect(validatePodRuleSpec(matchAll, field.NewPath("spec"))).To(gomega.BeEmpty())
+
+	invalid := &kuberule.PodRuleSpec{
+		ApplyOrder: -1,
+		NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpExists, Values: []string{"production"}},
+		}},
+		ImagePullSecretSources: []kuberule.SecretSourceReference{
+			{Name: "registry-creds"},
+		},
+		Profiles: []kuberule.PodMutationProfileReference{
+			{Kind: "Secret", Name: "Base_Profile"},
+		},
+		Mutations: kuberule.PodMutations{
+			Labels:           map[string]string{"tier": "not a value"},
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "Dockerhub_Creds"}},
+			Tolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "gpu"},
+				{Operator: corev1.TolerationOpEqual},
+			},
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{
+						Weight: 0,
+						Preference: corev1.NodeSelectorTerm{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{Key: "example.com/cores", Operator: corev1.NodeSelectorOpGt, Values: []string{"many"}},
+							},
+						},
+					}},
+				},
+			},
+			ServiceAccountName: "Not_Valid",
+			DNSPolicy:          corev1.DNSNone,
+			HostAliases:        []corev1.HostAlias{{IP: "legacy", Hostnames: []string{"legacy.example.com"}}},
+			PreStop: &kuberule.ContainerPreStop{
+				Containers: []string{"Istio_Proxy"},
+				Handler: corev1.Handler{
+					Exec:    &corev1.ExecAction{Command: []string{"sleep", "15"}},
+					HTTPGet: &corev1.HTTPGetAction{Path: "/shutdown"},
+				},
+			},
+			ReadinessGates: []corev1.PodReadinessGate{{ConditionType: "not a condition"}},
+			ImageRegistryRewrites: []kuberule.ImageRegistryRewrite{
+				{From: "docker.io/", To: "dockerhub/"},
+				{From: "registry.internal/", To: "registry.internal/mirror/"},
+			},
+			ImagePullPolicy: "Sometimes",
+			Volumes: []corev1.Volume{{Name: "docker", VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"},
+			}}},
+			VolumeMounts: []corev1.VolumeMount{{Name: "docker", MountPath: "var/run/docker.sock"}},
+			Containers: &kuberule.ContainerSelector{
+				ExcludeNames: []string{"istio-[proxy"},
+				Types:        []kuberule.ContainerType{"Ephemeral"},
+			},
+			Strategies: kuberule.PodMutationStrategies{
+				Affinity:           kuberule.MutationStrategyMerge,
+				ServiceAccountName: kuberule.MutationStrategyMerge,
+			},
+		},
+	}
+	g.Expect(fields(validatePodRuleSpec(invalid, field.NewPath("spec")))).To(gomega.ConsistOf(
+		"spec.applyOrder",
+		"spec.matchAll",
+		"spec.namespaceSelector.matchExpressions[0].values",
+		"spec.profiles[0].kind",
+		"spec.profiles[0].name",
+		"spec.mutations.labels",
+		"spec.mutations.affinity.nodeAffinity.preferredDuringSchedulingIgnoredDuringExecution[0].weight",
+		"spec.mutations.affinity.nodeAffinity.preferredDuringSchedulingIgnoredDuringExecution[0].preference.matchExpressions[0].values[0]",
+		"spec.mutations.imagePullSecrets[0].name",
+		"spec.imagePullSecretSources[0].namespace",
+		"spec.mutations.tolerations[0].value",
+		"spec.mutations.tolerations[1].operator",
+		"spec.mutations.serviceAccountName",
+		"spec.mutations.dnsConfig.nameservers",
+		"spec.mutations.hostAliases[0].ip",
+		"spec.mutations.preStop.containers[0]",
+		"spec.mutations.preStop.handler",
+		"spec.mutations.readinessGates[0].conditionType",
+		"spec.mutations.imageRegistryRewrites[0].to",
+		"spec.mutations.imageRegistryRewrites[1].to",
+		"spec.mutations.imagePullPolicy",
+		"spec.mutations.volumes[0]",
+		"spec.mutations.volumeMounts[0].mountPath",
+		"spec.mutations.containers.excludeNames[0]",
+		"spec.mutations.containers.types[0]",
+		"spec.mutations.strategies.affinity",
+		"spec.mutations.strategies.serviceAccountName",
+	))
+}
+
+func TestValidatePodMutationStrategiesOrder(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	strategies := &kuberule.PodMutationStrategies{
+		Tolerations:        "Replace",
+		Annotations:        "Replace",
+		Labels:             "Replace",
+		DNSPolicy:          kuberule.MutationStrategyMerge,
+		Affinity:           kuberule.MutationStrategyMerge,
+		ServiceAccountName: kuberule.MutationStrategyMerge,
+	}
+
+	errs := validatePodMutationStrategies(strategies, field.NewPath("strategies"))
+	for i := 0; i < 10; i++ {
+		g.Expect(validatePodMutationStrategies(strategies, field.NewPath("strategies")).ToAggregate().Error()).
+			To(gomega.Equal(errs.ToAggregate().Error()))
+	}
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	g.Expect(fields).To(gomega.Equal([]string{
+		"strategies.annotations",
+		"strategies.labels",
+		"strategies.tolerations",
+		"strategies.affinity",
+		"strategies.dnsPolicy",
+		"strategies.serviceAccountName",
+	}))
+}
@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// webhookServer serves the registered webhooks over TLS.
+// Certificates are picked on each handshake, so rotated ones are served without restarting.
+type webhookServer struct {
+	port         int
+	certificates func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	mux      *http.ServeMux
+	webhooks []webhook.Webhook
+}
+
+var _ manager.Runnable = &webhookServer{}
+
+// Register validates and serves the webhooks at their path
+func (s *webhookServer) Register(webhooks ...webhook.Webhook) error {
+	if s.mux == nil {
+		s.mux = http.NewServeMux()
+	}
+
+	for _, w := range webhooks {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		for _, registered := range s.webhooks {
+			if registered.GetPath() == w.GetPath() {
+				return fmt.Errorf("webhooks %s and %s are both served at %s", registered.GetName(), w.GetName(), w.GetPath())
+			}
+		}
+		s.mux.Handle(w.GetPath(), w.Handler())
+		s.webhooks = append(s.webhooks, w)
+	}
+
+	return nil
+}
+
+// Start implements manager.Runnable
+func (s *webhookServer) Start(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:      fmt.Sprintf(":%d", s.port),
+		Handler:   s.mux,
+		TLSConfig: &tls.Config{GetCertificate: s.certificates},
+	}
+
+	errCh := make(chan error)
+	go func() {
+		log.Info("starting the webhook server", "port", s.port)
+		// certificates come from the TLS config
+		errCh <- server.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case <-stop:
+		return server.Shutdown(context.Background())
+	case err := <-errCh:
+		return err
+	}
+}
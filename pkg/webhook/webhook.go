@@ -1,24 +1,36 @@
 package webhook
 
 import (
-	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1alpha1"
+	"fmt"
+	"time"
+
+	kuberule "github.com/chickenzord/kube-rule/pkg/apis/kuberule/v1beta1"
 	"github.com/chickenzord/kube-rule/pkg/config"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
-	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/builder"
 )
 
 var log = logf.Log.WithName("webhook.kuberule")
 
-func createMutatePodsWebhook(mgr manager.Manager) (*admission.Webhook, error) {
-	// TODO: add namespace selector
+const (
+	mutatePodsWebhookName       = "mutatepods.kuberule.chickenzord.com"
+	validatePodsWebhookName     = "validatepods.kuberule.chickenzord.com"
+	validatePodRulesWebhookName = "validatepodrules.kuberule.chickenzord.com"
+	mutatePodRulesWebhookName   = "mutatepodrules.kuberule.chickenzord.com"
+
+	validatePodMutationProfilesWebhookName = "validatepodmutationprofiles.kuberule.chickenzord.com"
+)
+
+func createMutatePodsWebhook(mgr manager.Manager, audits *auditQueue) (*admission.Webhook, error) {
 	return builder.NewWebhookBuilder().
-		Name("mutatepods.kuberule.chickenzord.com").
+		Name(mutatePodsWebhookName).
 		Mutating().
 		Operations(
 			admissionregistrationv1beta1.Create,
@@ -26,18 +38,40 @@ func createMutatePodsWebhook(mgr manager.Manager) (*admission.Webhook, error) {
 		).
 		ForType(&corev1.Pod{}).
 		Handlers(&podMutationHandler{
-			client:  mgr.GetClient(),
-			decoder: mgr.GetAdmissionDecoder(),
+			client:   mgr.GetClient(),
+			decoder:  mgr.GetAdmissionDecoder(),
+			recorder: mgr.GetRecorder(config.AppName),
+			audits:   audits,
 		}).
-		FailurePolicy(admissionregistrationv1beta1.Ignore).
+		FailurePolicy(admissionregistrationv1beta1.FailurePolicyType(config.MutatePodsWebhook.FailurePolicy)).
+		NamespaceSelector(config.MutatePodsWebhook.NamespaceSelector).
+		WithManager(mgr).
+		Build()
+}
+
+func createValidatePodsWebhook(mgr manager.Manager) (*admission.Webhook, error) {
+	return builder.NewWebhookBuilder().
+		Name(validatePodsWebhookName).
+		Validating().
+		Operations(
+			admissionregistrationv1beta1.Create,
+			admissionregistrationv1beta1.Update,
+		).
+		ForType(&corev1.Pod{}).
+		Handlers(&podValidationHandler{
+			client:   mgr.GetClient(),
+			decoder:  mgr.GetAdmissionDecoder(),
+			recorder: mgr.GetRecorder(config.AppName),
+		}).
+		FailurePolicy(admissionregistrationv1beta1.FailurePolicyType(config.ValidatePodsWebhook.FailurePolicy)).
+		NamespaceSelector(config.ValidatePodsWebhook.NamespaceSelector).
 		WithManager(mgr).
 		Build()
 }
 
 func createValidatePodRulesWebhook(mgr manager.Manager) (*admission.Webhook, error) {
-	// TODO: add namespace selector
 	return builder.NewWebhookBuilder().
-		Name("validatepodrules.kuberule.chickenzord.com").
+		Name(validatePodRulesWebhookName).
 		Validating().
 		Operations(
 			admissionregistrationv1beta1.Create,
@@ -48,15 +82,15 @@ func createValidatePodRulesWebhook(mgr manager.Manager) (*admission.Webhook, err
 			client:  mgr.GetClient(),
 			decoder: mgr.GetAdmissionDecoder(),
 		}).
-		FailurePolicy(admissionregistrationv1beta1.Ignore).
+		FailurePolicy(admissionregistrationv1beta1.FailurePolicyType(config.ValidatePodRulesWebhook.FailurePolicy)).
+		NamespaceSelector(config.ValidatePodRulesWebhook.NamespaceSelector).
 		WithManager(mgr).
 		Build()
 }
 
 func createMutatePodRulesWebhook(mgr manager.Manager) (*admission.Webhook, error) {
-	// TODO: add namespace selector
 	return builder.NewWebhookBuilder().
-		Name("mutatepodrules.kuberule.chickenzord.com").
+		Name(mutatePodRulesWebhookName).
 		Mutating().
 		Operations(
 			admissionregistrationv1beta1.Create,
@@ -67,37 +101,148 @@ func createMutatePodRulesWebhook(mgr manager.Manager) (*admission.Webhook, error
 			client:  mgr.GetClient(),
 			decoder: mgr.GetAdmissionDecoder(),
 		}).
-		FailurePolicy(admissionregistrationv1beta1.Ignore).
+		FailurePolicy(admissionregistrationv1beta1.FailurePolicyType(config.MutatePodRulesWebhook.FailurePolicy)).
+		NamespaceSelector(config.MutatePodRulesWebhook.NamespaceSelector).
+		WithManager(mgr).
+		Build()
+}
+
+func createValidatePodMutationProfilesWebhook(mgr manager.Manager) (*admission.Webhook, error) {
+	return builder.NewWebhookBuilder().
+		Name(validatePodMutationProfilesWebhookName).
+		Validating().
+		Operations(
+			admissionregistrationv1beta1.Create,
+			admissionregistrationv1beta1.Update,
+		).
+		ForType(&kuberule.PodMutationProfile{}).
+		Handlers(&podMutationProfileValidationHandler{
+			client:  mgr.GetClient(),
+			decoder: mgr.GetAdmissionDecoder(),
+		}).
+		FailurePolicy(admissionregistrationv1beta1.FailurePolicyType(config.ValidatePodMutationProfilesWebhook.FailurePolicy)).
+		NamespaceSelector(config.ValidatePodMutationProfilesWebhook.NamespaceSelector).
 		WithManager(mgr).
 		Build()
 }
 
-func createServer(mgr manager.Manager) (*webhook.Server, error) {
-	return webhook.NewServer(config.AppName, mgr, webhook.ServerOptions{
-		CertDir: config.CertDir,
-		BootstrapOptions: &webhook.BootstrapOptions{
-			MutatingWebhookConfigName:   config.AppName,
-			ValidatingWebhookConfigName: config.AppName,
-
-			Secret: &types.NamespacedName{
-				Namespace: config.Namespace,
-				Name:      config.SecretName,
-			},
-
-			Service: &webhook.Service{
-				Namespace: config.Namespace,
-				Name:      config.ServiceName,
-				// Selectors should select the pods that runs this webhook server.
-				Selectors: config.ServiceSelector,
-			},
+func createConfigPatcher(mgr manager.Manager, rotator *certRotator, webhooks ...*admission.Webhook) (*webhookConfigPatcher, error) {
+	// use a direct client, unstructured objects are not served from the cache
+	c, err := client.New(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// PodRules of any served version are sent as v1beta1
+	mutating := map[string]webhookFields{
+		mutatePodsWebhookName:     {"reinvocationPolicy": config.PodsReinvocationPolicy},
+		mutatePodRulesWebhookName: {"matchPolicy": "Equivalent"},
+	}
+	validating := map[string]webhookFields{
+		validatePodsWebhookName:                {},
+		validatePodRulesWebhookName:            {"matchPolicy": "Equivalent"},
+		validatePodMutationProfilesWebhookName: {},
+	}
+	settings := map[string]config.WebhookSettings{
+		mutatePodsWebhookName:                  config.MutatePodsWebhook,
+		mutatePodRulesWebhookName:              config.MutatePodRulesWebhook,
+		validatePodsWebhookName:                config.ValidatePodsWebhook,
+		validatePodRulesWebhookName:            config.ValidatePodRulesWebhook,
+		validatePodMutationProfilesWebhookName: config.ValidatePodMutationProfilesWebhook,
+	}
+	for _, fields := range []map[string]webhookFields{mutating, validating} {
+		for name := range fields {
+			if err := addAdmissionFields(settings[name], fields[name]); err != nil {
+				return nil, fmt.Errorf("invalid settings of webhook %s: %s", name, err)
+			}
+		}
+	}
+
+	return &webhookConfigPatcher{
+		client:     c,
+		name:       config.AppName,
+		interval:   time.Minute,
+		webhooks:   webhooks,
+		caBundle:   rotator.CABundle,
+		mutating:   mutating,
+		validating: validating,
+		conversionCRDs: []string{
+			"podrules." + kuberule.SchemeGroupVersion.Group,
+			"podrulepolicies." + kuberule.SchemeGroupVersion.Group,
+		},
+		conversionPath: convertWebhookPath,
+		service: types.NamespacedName{
+			Namespace: config.Namespace,
+			Name:      config.ServiceName,
 		},
+		serviceSelector: config.ServiceSelector,
+		targetPort:      config.WebhookPort,
+	}, nil
+}
+
+// addAdmissionFields adds the webhook settings unknown to the webhook builder to the fields
+func addAdmissionFields(settings config.WebhookSettings, fields webhookFields) error {
+	fields["timeoutSeconds"] = int64(settings.TimeoutSeconds)
+	fields["sideEffects"] = settings.SideEffects
+	if settings.ObjectSelector != nil {
+		selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(settings.ObjectSelector)
+		if err != nil {
+			return err
+		}
+		fields["objectSelector"] = selector
+	}
+	return nil
+}
+
+func createCertRotator(mgr manager.Manager) (*certRotator, error) {
+	// use a direct client, the secret is read before the cache is started and secrets aren't watched
+	c, err := client.New(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &certRotator{
+		client: c,
+		secret: types.NamespacedName{
+			Namespace: config.Namespace,
+			Name:      config.SecretName,
+		},
+		interval: time.Minute,
+		now:      time.Now,
+		issue:    config.CertProvider == "self",
+		dnsNames: serviceDNSNames(types.NamespacedName{
+			Namespace: config.Namespace,
+			Name:      config.ServiceName,
+		}),
+		validity:    config.CertValidity,
+		caValidity:  config.CertCAValidity,
+		renewBefore: config.CertRenewBefore,
+	}, nil
+}
+
+func createServer(rotator *certRotator) *webhookServer {
+	return &webhookServer{
+		port:         config.WebhookPort,
+		certificates: rotator.GetCertificate,
+	}
 }
 
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
 var AddToManagerFuncs = []func(manager.Manager) error{
 	func(mgr manager.Manager) error {
-		mutatePodsWebhook, err := createMutatePodsWebhook(mgr)
+		audits := newAuditQueue(mgr.GetClient())
+		mutatePodsWebhook, err := createMutatePodsWebhook(mgr, audits)
+		if err != nil {
+			return err
+		}
+
+		validatePodsWebhook, err := createValidatePodsWebhook(mgr)
 		if err != nil {
 			return err
 		}
@@ -112,16 +257,46 @@ var AddToManagerFuncs = []func(manager.Manager) error{
 			return err
 		}
 
-		server, err := createServer(mgr)
+		validatePodMutationProfilesWebhook, err := createValidatePodMutationProfilesWebhook(mgr)
 		if err != nil {
 			return err
 		}
 
-		return server.Register(
+		rotator, err := createCertRotator(mgr)
+		if err != nil {
+			return err
+		}
+
+		server := createServer(rotator)
+		if err := server.Register(
+			mutatePodsWebhook,
+			validatePodsWebhook,
+			validatePodRulesWebhook,
+			mutatePodRulesWebhook,
+			validatePodMutationProfilesWebhook,
+			&conversionWebhook{},
+		); err != nil {
+			return err
+		}
+
+		configPatcher, err := createConfigPatcher(mgr, rotator,
 			mutatePodsWebhook,
+			validatePodsWebhook,
 			validatePodRulesWebhook,
 			mutatePodRulesWebhook,
+			validatePodMutationProfilesWebhook,
 		)
+		if err != nil {
+			return err
+		}
+		rotator.caInstalled = configPatcher.caBundleInstalled
+
+		for _, runnable := range []manager.Runnable{rotator, server, configPatcher, audits} {
+			if err := mgr.Add(runnable); err != nil {
+				return err
+			}
+		}
+		return nil
 	},
 }
 
@@ -129,6 +304,13 @@ var AddToManagerFuncs = []func(manager.Manager) error{
 // +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podrulepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kuberule.chickenzord.com,resources=podmutationprofiles;clusterpodmutationprofiles,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;update
 func AddToManager(m manager.Manager) error {
 	for _, f := range AddToManagerFuncs {
 		if err := f(m); err != nil {
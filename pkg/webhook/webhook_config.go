@@ -0,0 +1,413 @@
+package webhook
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"reflect"
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	webhooktypes "sigs.k8s.io/controller-runtime/pkg/webhook/types"
+)
+
+// servicePort is the port of the webhook service called by the API servers
+const servicePort = 443
+
+var (
+	mutatingWebhookConfigurationGVK = schema.GroupVersionKind{
+		Group:   "admissionregistration.k8s.io",
+		Version: "v1beta1",
+		Kind:    "MutatingWebhookConfiguration",
+	}
+	validatingWebhookConfigurationGVK = schema.GroupVersionKind{
+		Group:   "admissionregistration.k8s.io",
+		Version: "v1beta1",
+		Kind:    "ValidatingWebhookConfiguration",
+	}
+	customResourceDefinitionGVK = schema.GroupVersionKind{
+		Group:   "apiextensions.k8s.io",
+		Version: "v1",
+		Kind:    "CustomResourceDefinition",
+	}
+)
+
+// webhookFields are extra fields set on a single webhook entry, keyed by json field name
+type webhookFields map[string]interface{}
+
+// webhookConfigPatcher installs the webhook service and configurations of the webhook server,
+// trusting the CA bundle of its certificates, along fields unknown to the webhook builder
+// (e.g. reinvocationPolicy). Certificates may be rotated and configurations edited at any time,
+// so they are reconciled periodically.
+type webhookConfigPatcher struct {
+	client   client.Client
+	name     string
+	interval time.Duration
+
+	// webhooks installed in the configurations, and the PEM encoded CAs to trust, empty until issued
+	webhooks []*admission.Webhook
+	caBundle func() []byte
+
+	// extra fields keyed by webhook name
+	mutating   map[string]webhookFields
+	validating map[string]webhookFields
+
+	// CRDs converted by the webhook server, at conversionPath of the webhook service
+	conversionCRDs []string
+	conversionPath string
+
+	// service selecting the pods of the webhook server, listening at targetPort
+	service         types.NamespacedName
+	serviceSelector labels.Set
+	targetPort      int
+}
+
+var _ manager.Runnable = &webhookConfigPatcher{}
+
+// Start implements manager.Runnable
+func (p *webhookConfigPatcher) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		caBundle := p.caBundle()
+		if len(caBundle) == 0 {
+			log.Info("waiting for certificates to install webhook configurations", "name", p.name)
+			return
+		}
+
+		if err := p.patchService(context.TODO()); err != nil {
+			log.Error(err, "unable to patch webhook service", "service", p.service)
+		}
+		if err := p.patch(context.TODO(), mutatingWebhookConfigurationGVK, webhooktypes.WebhookTypeMutating, p.mutating, caBundle); err != nil {
+			log.Error(err, "unable to patch mutating webhook configuration", "name", p.name)
+		}
+		if err := p.patch(context.TODO(), validatingWebhookConfigurationGVK, webhooktypes.WebhookTypeValidating, p.validating, caBundle); err != nil {
+			log.Error(err, "unable to patch validating webhook configuration", "name", p.name)
+		}
+		if err := p.patchConversion(context.TODO(), caBundle); err != nil {
+			log.Error(err, "unable to patch conversion webhook of CRDs")
+		}
+	}, p.interval, stop)
+
+	return nil
+}
+
+// patchService creates the service of the webhook server, or fixes its selector and port if changed
+func (p *webhookConfigPatcher) patchService(ctx context.Context) error {
+	ports := []corev1.ServicePort{{
+		Port:       servicePort,
+		TargetPort: intstr.FromInt(p.targetPort),
+	}}
+
+	service := &corev1.Service{}
+	err := p.client.Get(ctx, p.service, service)
+	if errors.IsNotFound(err) {
+		service = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: p.service.Namespace, Name: p.service.Name},
+			Spec: corev1.ServiceSpec{
+				Selector: p.serviceSelector,
+				Ports:    ports,
+			},
+		}
+		log.Info("creating webhook service", "service", p.service)
+		return p.client.Create(ctx, service)
+	}
+	if err != nil {
+		return err
+	}
+
+	if labels.Equals(service.Spec.Selector, p.serviceSelector) && len(service.Spec.Ports) == 1 &&
+		service.Spec.Ports[0].Port == servicePort && service.Spec.Ports[0].TargetPort == ports[0].TargetPort {
+		return nil
+	}
+
+	service.Spec.Selector = p.serviceSelector
+	service.Spec.Ports = ports
+	log.Info("patching webhook service", "service", p.service)
+	return p.client.Update(ctx, service)
+}
+
+// desiredWebhooks returns the entries of the webhooks of the given type,
+// trusting the CA bundle and with the extra fields set
+func (p *webhookConfigPatcher) desiredWebhooks(webhookType webhooktypes.WebhookType, fields map[string]webhookFields, caBundle []byte) ([]interface{}, error) {
+	entries := []interface{}{}
+	for _, w := range p.webhooks {
+		if w.GetType() != webhookType {
+			continue
+		}
+
+		path := w.GetPath()
+		entry, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&admissionregistrationv1beta1.Webhook{
+			Name: w.GetName(),
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+				Service: &admissionregistrationv1beta1.ServiceReference{
+					Namespace: p.service.Namespace,
+					Name:      p.service.Name,
+					Path:      &path,
+				},
+				CABundle: caBundle,
+			},
+			Rules:             w.Rules,
+			FailurePolicy:     w.FailurePolicy,
+			NamespaceSelector: w.NamespaceSelector,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range fields[w.GetName()] {
+			entry[key] = val
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// patch installs the webhooks of the given type in the webhook configuration, if changed.
+// Fields defaulted by the API server are kept.
+func (p *webhookConfigPatcher) patch(ctx context.Context, gvk schema.GroupVersionKind, webhookType webhooktypes.WebhookType, fields map[string]webhookFields, caBundle []byte) error {
+	desired, err := p.desiredWebhooks(webhookType, fields, caBundle)
+	if err != nil || len(desired) == 0 {
+		return err
+	}
+
+	configuration := &unstructured.Unstructured{}
+	configuration.SetGroupVersionKind(gvk)
+	err = p.client.Get(ctx, types.NamespacedName{Name: p.name}, configuration)
+	if errors.IsNotFound(err) {
+		configuration.SetName(p.name)
+		configuration.Object["webhooks"] = desired
+		log.Info("creating webhook configuration",
+			"kind", gvk.Kind,
+			"name", p.name,
+		)
+		return p.client.Create(ctx, configuration)
+	}
+	if err != nil {
+		return err
+	}
+
+	existing, _, err := unstructured.NestedSlice(configuration.Object, "webhooks")
+	if err != nil {
+		return err
+	}
+	existingByName := map[string]map[string]interface{}{}
+	for _, item := range existing {
+		if webhook, ok := item.(map[string]interface{}); ok {
+			name, _ := webhook["name"].(string)
+			existingByName[name] = webhook
+		}
+	}
+
+	// removed webhooks are dropped, unchanged ones kept as defaulted by the API server. Fields
+	// removed from the desired webhooks are reset to their defaults.
+	changed := len(existing) != len(desired)
+	webhooks := []interface{}{}
+	for _, item := range desired {
+		webhook := item.(map[string]interface{})
+		name, _ := webhook["name"].(string)
+		if current, ok := existingByName[name]; ok && managedFieldsEqual(webhook, current) {
+			webhooks = append(webhooks, current)
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	configuration.Object["webhooks"] = webhooks
+
+	log.Info("patching webhook configuration",
+		"kind", gvk.Kind,
+		"name", p.name,
+	)
+	return p.client.Update(ctx, configuration)
+}
+
+// managedFieldsEqual checks whether the fields managed in desired, i.e. set in desired or defaulted
+// by the API server, have the same value in actual. Unset fields equal their defaults, other fields
+// of actual are ignored.
+func managedFieldsEqual(desired, actual map[string]interface{}) bool {
+	desired, actual = defaultWebhook(desired), defaultWebhook(actual)
+	for key, val := range desired {
+		if !reflect.DeepEqual(val, actual[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookDefaults are the values set by the API server for the fields of a webhook entry left unset
+var webhookDefaults = map[string]interface{}{
+	"failurePolicy":           "Ignore",
+	"matchPolicy":             "Exact",
+	"reinvocationPolicy":      "Never",
+	"sideEffects":             "Unknown",
+	"timeoutSeconds":          int64(30),
+	"namespaceSelector":       map[string]interface{}{},
+	"objectSelector":          map[string]interface{}{},
+	"admissionReviewVersions": []interface{}{"v1beta1"},
+}
+
+// defaultWebhook returns a copy of the webhook entry with the unset fields defaulted,
+// as done by the API server
+func defaultWebhook(webhook map[string]interface{}) map[string]interface{} {
+	defaulted := map[string]interface{}{}
+	for key, val := range webhookDefaults {
+		defaulted[key] = val
+	}
+	for key, val := range webhook {
+		defaulted[key] = val
+	}
+
+	if rules, ok := webhook["rules"].([]interface{}); ok {
+		defaultedRules := []interface{}{}
+		for _, item := range rules {
+			if rule, ok := item.(map[string]interface{}); ok {
+				item = withDefault(rule, "scope", "*")
+			}
+			defaultedRules = append(defaultedRules, item)
+		}
+		defaulted["rules"] = defaultedRules
+	}
+
+	if clientConfig, ok := webhook["clientConfig"].(map[string]interface{}); ok {
+		if service, ok := clientConfig["service"].(map[string]interface{}); ok {
+			clientConfig = withDefault(clientConfig, "service", withDefault(service, "port", int64(servicePort)))
+		}
+		defaulted["clientConfig"] = clientConfig
+	}
+
+	return defaulted
+}
+
+// withDefault returns a copy of the object with the field set to the value, if unset
+func withDefault(object map[string]interface{}, field string, val interface{}) map[string]interface{} {
+	defaulted := map[string]interface{}{field: val}
+	for key, val := range object {
+		defaulted[key] = val
+	}
+	return defaulted
+}
+
+// caBundleInstalled checks whether the CA is trusted by all the installed webhooks and conversions
+func (p *webhookConfigPatcher) caBundleInstalled(ctx context.Context, ca *x509.Certificate) (bool, error) {
+	caBundles := []string{}
+
+	for _, gvk := range []schema.GroupVersionKind{mutatingWebhookConfigurationGVK, validatingWebhookConfigurationGVK} {
+		configuration := &unstructured.Unstructured{}
+		configuration.SetGroupVersionKind(gvk)
+		err := p.client.Get(ctx, types.NamespacedName{Name: p.name}, configuration)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		webhooks, _, err := unstructured.NestedSlice(configuration.Object, "webhooks")
+		if err != nil {
+			return false, err
+		}
+		for _, item := range webhooks {
+			webhook, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			caBundle, _, _ := unstructured.NestedString(webhook, "clientConfig", "caBundle")
+			caBundles = append(caBundles, caBundle)
+		}
+	}
+
+	for _, name := range p.conversionCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(customResourceDefinitionGVK)
+		if err := p.client.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+			return false, err
+		}
+		caBundle, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+		caBundles = append(caBundles, caBundle)
+	}
+
+	for _, caBundle := range caBundles {
+		if !trustsCA(caBundle, ca) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// trustsCA checks whether the base64 encoded PEM bundle contains the CA
+func trustsCA(caBundle string, ca *x509.Certificate) bool {
+	decoded, err := base64.StdEncoding.DecodeString(caBundle)
+	if err != nil {
+		return false
+	}
+	for _, certificate := range parseCertificates(decoded) {
+		if certificate.Equal(ca) {
+			return true
+		}
+	}
+	return false
+}
+
+// patchConversion points the conversion of the CRDs to the webhook server, if changed
+func (p *webhookConfigPatcher) patchConversion(ctx context.Context, caBundle []byte) error {
+	if len(p.conversionCRDs) == 0 {
+		return nil
+	}
+
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"conversionReviewVersions": []interface{}{"v1beta1"},
+			"clientConfig": map[string]interface{}{
+				"caBundle": base64.StdEncoding.EncodeToString(caBundle),
+				"service": map[string]interface{}{
+					"namespace": p.service.Namespace,
+					"name":      p.service.Name,
+					"path":      p.conversionPath,
+					"port":      int64(servicePort),
+				},
+			},
+		},
+	}
+
+	for _, name := range p.conversionCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(customResourceDefinitionGVK)
+		if err := p.client.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+			return err
+		}
+
+		existing, _, err := unstructured.NestedMap(crd.Object, "spec", "conversion")
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(existing, conversion) {
+			continue
+		}
+		if err := unstructured.SetNestedMap(crd.Object, conversion, "spec", "conversion"); err != nil {
+			return err
+		}
+
+		log.Info("patching conversion webhook", "crd", name)
+		if err := p.client.Update(ctx, crd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
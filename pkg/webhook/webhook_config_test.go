@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestManagedFieldsEqual(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	desired := map[string]interface{}{
+		"name":           "foo",
+		"rules":          []interface{}{map[string]interface{}{"operations": []interface{}{"CREATE"}}},
+		"objectSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "foo"}},
+	}
+	defaulted := map[string]interface{}{
+		"name":                    "foo",
+		"rules":                   []interface{}{map[string]interface{}{"operations": []interface{}{"CREATE"}, "scope": "*"}},
+		"objectSelector":          map[string]interface{}{"matchLabels": map[string]interface{}{"app": "foo"}},
+		"namespaceSelector":       map[string]interface{}{},
+		"sideEffects":             "Unknown",
+		"timeoutSeconds":          int64(30),
+		"admissionReviewVersions": []interface{}{"v1beta1"},
+		"unknownField":            "bar",
+	}
+
+	// fields defaulted by the API server and unknown ones are ignored
+	g.Expect(managedFieldsEqual(desired, defaulted)).To(gomega.BeTrue())
+	g.Expect(managedFieldsEqual(desired, map[string]interface{}{
+		"name":           "foo",
+		"rules":          []interface{}{map[string]interface{}{"operations": []interface{}{"CREATE"}}},
+		"objectSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "foo"}},
+	})).To(gomega.BeTrue())
+
+	// changed fields
+	g.Expect(managedFieldsEqual(desired, map[string]interface{}{
+		"name":  "foo",
+		"rules": []interface{}{map[string]interface{}{"operations": []interface{}{"CREATE", "UPDATE"}}},
+	})).To(gomega.BeFalse())
+	g.Expect(managedFieldsEqual(desired, map[string]interface{}{"name": "foo"})).To(gomega.BeFalse())
+
+	// narrowed fields
+	g.Expect(managedFieldsEqual(desired, map[string]interface{}{
+		"name":           "foo",
+		"rules":          []interface{}{map[string]interface{}{"operations": []interface{}{"CREATE"}}},
+		"objectSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "foo", "tier": "web"}},
+	})).To(gomega.BeFalse())
+
+	// removed fields
+	delete(desired, "objectSelector")
+	g.Expect(managedFieldsEqual(desired, defaulted)).To(gomega.BeFalse())
+	defaulted["objectSelector"] = map[string]interface{}{}
+	g.Expect(managedFieldsEqual(desired, defaulted)).To(gomega.BeTrue())
+	g.Expect(managedFieldsEqual(map[string]interface{}{"name": "foo", "timeoutSeconds": int64(10)}, defaulted)).To(gomega.BeFalse())
+}
+
+func TestTrustsCA(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rotator := &certRotator{
+		dnsNames:   serviceDNSNames(types.NamespacedName{Namespace: "kuberule", Name: "kuberule"}),
+		validity:   90 * 24 * time.Hour,
+		caValidity: 365 * 24 * time.Hour,
+	}
+	now := time.Now()
+	former, _, err := rotator.rotate(nil, now, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	renewed, _, err := rotator.rotate(nil, now, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	ca := parseCertificates(renewed[secretCACertKey])[0]
+
+	g.Expect(trustsCA(base64.StdEncoding.EncodeToString(former[secretCACertKey]), ca)).To(gomega.BeFalse())
+	bundle := append(append([]byte{}, renewed[secretCACertKey]...), former[secretCACertKey]...)
+	g.Expect(trustsCA(base64.StdEncoding.EncodeToString(bundle), ca)).To(gomega.BeTrue())
+	g.Expect(trustsCA("", ca)).To(gomega.BeFalse())
+}